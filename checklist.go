@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+func checkCmd(ctxClient *ctxclient.ContextClient, current *ctxclient.Context, args []string) string {
+	if current.ContextId == "" {
+		return "no current context"
+	}
+	cmd := "ls"
+	if len(args) > 0 {
+		cmd = args[0]
+		args = args[1:]
+	}
+	notes, err := readNotes(current.Notes)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	switch cmd {
+	case "ls", "list":
+		return checklistListing(current.Name, notes)
+	case "a", "add":
+		text := strings.TrimSpace(strings.Join(args, " "))
+		if text == "" {
+			text = getLine("checklist item: ", true)
+		}
+		last := -1
+		for i, n := range notes {
+			if n.Type == noteTypeChecklist {
+				last = i
+			}
+		}
+		if last == -1 {
+			notes = append(notes, note{
+				Type:   noteTypeChecklist,
+				At:     time.Now().UTC().Format(ctxclient.SkDateFormat),
+				Source: noteSourceManual,
+			})
+			last = len(notes) - 1
+		}
+		notes[last].Items = append(notes[last].Items, checklistItem{Text: text})
+	case "d", "done", "u", "undo":
+		if len(args) == 0 {
+			fmt.Printf("Error: missing checklist item number\n")
+			os.Exit(exitUsage)
+		}
+		index, err := strconv.Atoi(args[0])
+		if err != nil || index < 1 {
+			fmt.Printf("Error: invalid checklist item '%s', use the number from ctx check\n", args[0])
+			os.Exit(exitUsage)
+		}
+		item := checklistItemAt(notes, index)
+		if item == nil {
+			_, total := checklistCount(notes)
+			fmt.Printf("Error: no checklist item %d, there are %d items\n", index, total)
+			os.Exit(exitUsage)
+		}
+		item.Done = cmd == "d" || cmd == "done"
+	default:
+		usageError(fmt.Sprintf("unknown check command '%s'", cmd), "", "ctx help check")
+	}
+	current.Notes = encodeNotes(notes)
+	_, err = ctxClient.UpdateContext(current)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return checklistListing(current.Name, notes)
+}
+
+func checklistItemAt(notes []note, index int) *checklistItem {
+	// items are numbered across every checklist on the context
+	count := 0
+	for i := range notes {
+		if notes[i].Type != noteTypeChecklist {
+			continue
+		}
+		for j := range notes[i].Items {
+			count++
+			if count == index {
+				return &notes[i].Items[j]
+			}
+		}
+	}
+	return nil
+}
+
+func checklistCount(notes []note) (int, int) {
+	done, total := 0, 0
+	for _, n := range notes {
+		if n.Type != noteTypeChecklist {
+			continue
+		}
+		for _, item := range n.Items {
+			total++
+			if item.Done {
+				done++
+			}
+		}
+	}
+	return done, total
+}
+
+func checklistProgress(raw json.RawMessage) string {
+	notes, err := readNotes(raw)
+	if err != nil {
+		return ""
+	}
+	done, total := checklistCount(notes)
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d done", done, total)
+}
+
+func checklistListing(name string, notes []note) string {
+	done, total := checklistCount(notes)
+	if total == 0 {
+		return fmt.Sprintf("no checklist on '%s', add one with\nctx check add <item>", name)
+	}
+	output := fmt.Sprintf("checklist for '%s' (%d/%d done):\n", name, done, total)
+	count := 0
+	for _, n := range notes {
+		if n.Type != noteTypeChecklist {
+			continue
+		}
+		for _, item := range n.Items {
+			count++
+			box := "[ ]"
+			if item.Done {
+				box = "[x]"
+			}
+			output += fmt.Sprintf("%d. %s %s\n", count, box, item.Text)
+		}
+	}
+	return output
+}
+
+func carryChecklists(notes []string) ([]string, error) {
+	carried := []string{}
+	for _, s := range notes {
+		n := parseNote(s)
+		if n.Type != noteTypeChecklist {
+			carried = append(carried, s)
+			continue
+		}
+		open := []checklistItem{}
+		for _, item := range n.Items {
+			if !item.Done {
+				open = append(open, item)
+			}
+		}
+		if len(open) == 0 {
+			continue
+		}
+		n.Items = open
+		encoded, err := encodeNote(n)
+		if err != nil {
+			return nil, err
+		}
+		carried = append(carried, encoded)
+	}
+	return carried, nil
+}
+
+func openChecklists(raw json.RawMessage) ([]string, error) {
+	notes, err := readNotes(raw)
+	if err != nil {
+		return nil, err
+	}
+	open := []string{}
+	for _, n := range notes {
+		if n.Type != noteTypeChecklist {
+			continue
+		}
+		items := []checklistItem{}
+		for _, item := range n.Items {
+			if !item.Done {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+		n.Items = items
+		encoded, err := encodeNote(n)
+		if err != nil {
+			return nil, err
+		}
+		open = append(open, encoded)
+	}
+	return open, nil
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+const (
+	// ctx or the api ran into a problem
+	exitError = 1
+	// the command was used wrong: unknown command or flag, missing or invalid
+	// arguments
+	exitUsage = 2
+)
+
+type command struct {
+	Name    string
+	Aliases []string
+	// arguments shown after the name in help, like <queueId>
+	Args    string
+	Summary string
+	// more detail shown in the command's own help
+	Details     string
+	Flags       []commandFlag
+	Subcommands []*command
+	// the first argument can be text when it isn't a subcommand, like a note
+	Text bool
+	// also reports on updates and overdue queue items, see withReports
+	Reports bool
+	// gets the args after the command's (and subcommand's) name, flags included
+	Run func(env *commandEnv, args []string) string
+}
+
+type commandFlag struct {
+	Name  string
+	Short string
+	// what the flag takes, empty for a switch
+	Value string
+	Usage string
+}
+
+type commandEnv struct {
+	ctxClient *ctxclient.ContextClient
+	qClient   *ctxclient.QueueClient
+	current   *ctxclient.Context
+}
+
+var (
+	editFlag       = commandFlag{Name: "--edit", Short: "-e", Usage: "write notes in your editor"}
+	jsonNoteFlag   = commandFlag{Name: "--json", Value: "note", Usage: "add a structured note"}
+	kvNoteFlag     = commandFlag{Name: "--kv", Value: "key=value", Usage: "add to a kv note"}
+	jsonReportFlag = commandFlag{Name: "--json", Usage: "print the numbers instead of charts"}
+)
+
+func sinceFlag(def string) commandFlag {
+	return commandFlag{Name: "--since", Value: "window", Usage: fmt.Sprintf("how far back to look, like 2h, 1w or 30d (default %s)", def)}
+}
+
+func topFlag(what string) commandFlag {
+	return commandFlag{Name: "--top", Value: "n", Usage: fmt.Sprintf("how many %s to show (default 5)", what)}
+}
+
+func newRegistry() []*command {
+	queueCommands := []*command{
+		{Name: "get", Aliases: []string{"g"}, Args: "<queueId>", Summary: "get details of a queue item", Reports: true,
+			Run: func(env *commandEnv, args []string) string { return getQueue(env.qClient, args) }},
+		{Name: "add", Aliases: []string{"a"}, Summary: "add an item to the queue",
+			Flags: []commandFlag{
+				{Name: "--priority", Short: "-p", Value: "high|normal|low", Usage: "priority of the item (default normal)"},
+				{Name: "--due", Value: "date", Usage: "when it's due, like friday, eow or 2024-05-01"},
+				{Name: "--every", Value: "rule", Usage: "add it again when done, like weekly, monday or a cron rule"},
+				{Name: "--after", Value: "queueIds", Usage: "comma separated items it waits on"},
+				{Name: "--estimate", Value: "duration", Usage: "how long it should take, like 2h"},
+				{Name: "--under", Value: "contextId", Usage: "start it as a subcontext of this context"},
+				{Name: "--parent", Value: "queueId", Usage: "start it under the context of this item"},
+				editFlag,
+			},
+			Run: func(env *commandEnv, args []string) string { return addQueue(env.qClient, env.ctxClient, args) }},
+		{Name: "do", Aliases: []string{"d"}, Args: "<queueId>", Summary: "start a context from a queue item", Flags: []commandFlag{editFlag},
+			Run: func(env *commandEnv, args []string) string { return doQueue(env.qClient, env.ctxClient, args) }},
+		{Name: "note", Aliases: []string{"n"}, Args: "<queueId> | ls|edit|rm <queueId> [index]", Summary: "add a note to a queue item, or list, edit and remove its notes",
+			Details: "put - after the queueId to read notes from stdin",
+			Flags:   []commandFlag{editFlag, jsonNoteFlag, kvNoteFlag},
+			Run:     func(env *commandEnv, args []string) string { return noteQueue(env.qClient, args) }},
+		{Name: "close", Aliases: []string{"c"}, Args: "<queueId>", Summary: "take an item off the queue as done without starting it",
+			Run: func(env *commandEnv, args []string) string { return closeQueue(env.qClient, args) }},
+		{Name: "cancel", Args: "<queueId>", Summary: "take an item off the queue as cancelled",
+			Run: func(env *commandEnv, args []string) string {
+				return finishQueue(env.qClient, args, queueStateCancelled)
+			}},
+		{Name: "archive", Args: "<queueId>", Summary: "take an item off the queue for good, recurring items stop",
+			Run: func(env *commandEnv, args []string) string {
+				return finishQueue(env.qClient, args, queueStateArchived)
+			}},
+		{Name: "reopen", Args: "<queueId>", Summary: "put an item that left the queue back in it",
+			Run: func(env *commandEnv, args []string) string { return reopenQueue(env.qClient, args) }},
+		{Name: "snooze", Args: "<queueId>", Summary: "hide an item from ctx q for a while",
+			Flags: []commandFlag{{Name: "--until", Short: "-u", Value: "date", Usage: "when it comes back (default tomorrow)"}},
+			Run:   func(env *commandEnv, args []string) string { return snoozeQueue(env.qClient, args) }},
+		{Name: "history", Summary: "items that left the queue or came back", Flags: []commandFlag{sinceFlag("1w")},
+			Run: func(env *commandEnv, args []string) string { return historyQueue(args) }},
+		{Name: "import", Args: "<file>", Summary: "add tasks from a markdown, todo.txt or csv file",
+			Flags: []commandFlag{{Name: "--format", Short: "-f", Value: "markdown|todotxt|csv", Usage: "format of the file (default from the extension)"}},
+			Run:   func(env *commandEnv, args []string) string { return importQueue(env.qClient, env.ctxClient, args) }},
+		{Name: "export", Summary: "write the queue as markdown, todo.txt or csv",
+			Flags: []commandFlag{
+				{Name: "--format", Short: "-f", Value: "markdown|todotxt|csv", Usage: "format to write (default markdown, or from --out's extension)"},
+				{Name: "--out", Short: "-o", Value: "file", Usage: "write to a file instead of printing"},
+			},
+			Run: func(env *commandEnv, args []string) string { return exportQueue(env.qClient, args) }},
+		{Name: "next", Summary: "show the top of the queue and offer to start it", Flags: []commandFlag{editFlag},
+			Run: func(env *commandEnv, args []string) string { return nextQueue(env.qClient, env.ctxClient, args) }},
+		{Name: "move", Aliases: []string{"mv"}, Args: "<queueId>", Summary: "move an item within the queue",
+			Flags: []commandFlag{
+				{Name: "--top", Usage: "move it to the top of its priority"},
+				{Name: "--bottom", Usage: "move it to the bottom of its priority"},
+				{Name: "--before", Value: "queueId", Usage: "move it ahead of another item"},
+			},
+			Run: func(env *commandEnv, args []string) string { return moveQueue(env.qClient, args) }},
+		{Name: "priority", Aliases: []string{"pri"}, Args: "<queueId> <high|normal|low>", Summary: "change an item's priority",
+			Run: func(env *commandEnv, args []string) string { return priorityQueue(env.qClient, args) }},
+		{Name: "due", Args: "<queueId> <date|none>", Summary: "set or clear an item's due date",
+			Run: func(env *commandEnv, args []string) string { return dueQueue(env.qClient, args) }},
+		{Name: "overdue", Summary: "items past their due date",
+			Run: func(env *commandEnv, args []string) string { return overdueQueue(env.qClient) }},
+		{Name: "upcoming", Summary: "items due soon",
+			Flags: []commandFlag{{Name: "--days", Short: "-d", Value: "n", Usage: "how many days ahead to look (default 7)"}},
+			Run:   func(env *commandEnv, args []string) string { return upcomingQueue(env.qClient, args) }},
+		{Name: "graph", Summary: "show what items are waiting on",
+			Run: func(env *commandEnv, args []string) string { return graphQueue(env.qClient, env.ctxClient) }},
+		{Name: "estimate", Aliases: []string{"est"}, Args: "<queueId> <duration|none>", Summary: "set or clear an item's estimate",
+			Run: func(env *commandEnv, args []string) string { return estimateQueue(env.qClient, args) }},
+		{Name: "accuracy", Summary: "compare estimates with the time items actually took", Flags: []commandFlag{sinceFlag("30d")},
+			Run: func(env *commandEnv, args []string) string { return accuracyQueue(env.qClient, env.ctxClient, args) }},
+	}
+
+	commands := []*command{
+		{Name: "version", Aliases: []string{"v"}, Summary: "check for updates and print the current version",
+			Run: func(env *commandEnv, args []string) string { return checkVersions(env.ctxClient, true) }},
+		{Name: "get", Aliases: []string{"g"}, Args: "<contextId>", Summary: "get details of a context", Reports: true,
+			Run: func(env *commandEnv, args []string) string { return getCtx(env.ctxClient, args) }},
+		{Name: "last", Aliases: []string{"l"}, Summary: "show the last context",
+			Run: func(env *commandEnv, args []string) string { return lastCtx(env.ctxClient) }},
+		{Name: "list", Aliases: []string{"ls"}, Summary: "list contexts in a time window you're asked for",
+			Run: func(env *commandEnv, args []string) string { return listCtx(env.ctxClient) }},
+		{Name: "summary", Aliases: []string{"sum"}, Summary: "summary of contexts in a time window you're asked for",
+			Run: func(env *commandEnv, args []string) string { return summaryCtx(env.ctxClient) }},
+		{Name: "switch", Aliases: []string{"s"}, Summary: "switch to a new context", Flags: []commandFlag{editFlag},
+			Run: func(env *commandEnv, args []string) string { return switchCtx(env.ctxClient, env.current, args) }},
+		{Name: "sub", Aliases: []string{"-"}, Summary: "switch to a new context nested under the current context", Flags: []commandFlag{editFlag},
+			Run: func(env *commandEnv, args []string) string {
+				return switchCtx(env.ctxClient, env.current, append([]string{"sub"}, args...))
+			}},
+		{Name: "same", Aliases: []string{"="}, Summary: "switch to a new context with the same parent as the current context", Flags: []commandFlag{editFlag},
+			Run: func(env *commandEnv, args []string) string {
+				return switchCtx(env.ctxClient, env.current, append([]string{"same"}, args...))
+			}},
+		{Name: "note", Aliases: []string{"n"}, Args: "[-] [note]", Summary: "add a note to the current context, or list, edit and remove notes", Reports: true,
+			Details: "use - to read notes from stdin",
+			Flags:   []commandFlag{editFlag, jsonNoteFlag, kvNoteFlag},
+			Text:    true,
+			Subcommands: []*command{
+				{Name: "list", Aliases: []string{"ls"}, Args: "[contextId]", Summary: "list the notes on the current context (or contextId)",
+					Run: withSub("ls", noteRun)},
+				{Name: "edit", Args: "<index> [contextId]", Summary: "edit a note in your editor",
+					Run: withSub("edit", noteRun)},
+				{Name: "remove", Aliases: []string{"rm"}, Args: "<index> [contextId]", Summary: "remove a note",
+					Run: withSub("rm", noteRun)},
+			},
+			Run: noteRun},
+		{Name: "notes", Summary: "list notes added to any context", Flags: []commandFlag{sinceFlag("1d")},
+			Run: func(env *commandEnv, args []string) string { return listNotes(env.ctxClient, env.current, args) }},
+		{Name: "check", Summary: "show or update the checklist on the current context",
+			Subcommands: []*command{
+				{Name: "list", Aliases: []string{"ls"}, Summary: "show the checklist",
+					Run: withSub("ls", checkRun)},
+				{Name: "add", Aliases: []string{"a"}, Args: "<item>", Summary: "add an item to the checklist",
+					Run: withSub("add", checkRun)},
+				{Name: "done", Aliases: []string{"d"}, Args: "<number>", Summary: "tick an item off",
+					Run: withSub("done", checkRun)},
+				{Name: "undo", Aliases: []string{"u"}, Args: "<number>", Summary: "untick an item",
+					Run: withSub("undo", checkRun)},
+			},
+			Run: checkRun},
+		{Name: "focus", Args: "[length]", Summary: "run a focus timer on the current context (default CTX_FOCUS_LENGTH)",
+			Flags: []commandFlag{{Name: "--break", Short: "-b", Value: "length", Usage: "length of the break offered after (default CTX_FOCUS_BREAK)"}},
+			Run:   func(env *commandEnv, args []string) string { return focusCmd(env.ctxClient, env.current, args) }},
+		{Name: "pause", Summary: "take a break without closing the current context",
+			Run: func(env *commandEnv, args []string) string { return pauseCtx(env.ctxClient, env.current) }},
+		{Name: "unpause", Summary: "end the break on the current context",
+			Run: func(env *commandEnv, args []string) string { return unpauseCtx(env.ctxClient, env.current) }},
+		{Name: "gaps", Summary: "find and fix contexts that ran too long and untracked time",
+			Flags: []commandFlag{
+				sinceFlag("1w"),
+				{Name: "--max", Value: "length", Usage: "contexts longer than this are too long (default 4h)"},
+				{Name: "--gap", Value: "length", Usage: "report untracked time longer than this (default 30m)"},
+				{Name: "--night", Value: "hours", Usage: "contexts running through these hours are too long (default 0-6)"},
+			},
+			Run: func(env *commandEnv, args []string) string { return gapsCmd(env.ctxClient, args) }},
+		{Name: "goal", Summary: "set or remove a time goal for a root context or tag (progress by default)",
+			Subcommands: []*command{
+				{Name: "set", Args: "<name> <time>/<day|week>", Summary: "set a goal, like ctx goal set work 6h/day",
+					Run: withSub("set", goalRun)},
+				{Name: "remove", Aliases: []string{"rm"}, Args: "<name>", Summary: "remove the goals for a name",
+					Run: withSub("rm", goalRun)},
+			},
+			Run: goalRun},
+		{Name: "goals", Summary: "progress on every goal for today or this week",
+			Run: func(env *commandEnv, args []string) string { return goalsReport(env.ctxClient) }},
+		{Name: "stats", Summary: "charts of the time tracked", Flags: []commandFlag{sinceFlag("30d"), topFlag("contexts"), jsonReportFlag},
+			Run: func(env *commandEnv, args []string) string { return statsCmd(env.ctxClient, args) }},
+		{Name: "switches", Summary: "which contexts you bounce between and the most fragmented days", Flags: []commandFlag{sinceFlag("1w"), topFlag("pairs and days"), jsonReportFlag},
+			Run: func(env *commandEnv, args []string) string { return switchesCmd(env.ctxClient, args) }},
+		{Name: "close", Aliases: []string{"c"}, Args: "[contextId]", Summary: "close the current context (or contextId)", Reports: true,
+			Run: func(env *commandEnv, args []string) string { return closeCtx(env.ctxClient, args) }},
+		{Name: "resume", Aliases: []string{"r"}, Args: "<contextId>", Summary: "carry on with a context as a new one", Flags: []commandFlag{editFlag},
+			Run: func(env *commandEnv, args []string) string { return resumeCtx(env.ctxClient, args) }},
+		{Name: "timeMachine", Aliases: []string{"tm"}, Summary: "go back through the last contexts one at a time",
+			Run: func(env *commandEnv, args []string) string {
+				if env.current.LastContext == "" {
+					return fmt.Sprintf("current context '%s' has no last context\n", env.current.Name)
+				}
+				timeMachineCtx(env.ctxClient, env.current.LastContext)
+				return ""
+			}},
+		{Name: "parents", Aliases: []string{"p"}, Args: "[contextId]", Summary: "go up the parents of the current context (or contextId)",
+			Run: func(env *commandEnv, args []string) string {
+				if len(args) > 0 {
+					parentsCtx(env.ctxClient, args[0])
+					return ""
+				}
+				if env.current.ParentId == "" {
+					return fmt.Sprintf("current context '%s' has no parent\n", env.current.Name)
+				}
+				parentsCtx(env.ctxClient, env.current.ParentId)
+				return ""
+			}},
+		{Name: "q", Summary: "list the queue, or manage it with a subcommand",
+			Flags: []commandFlag{
+				{Name: "--context", Short: "-c", Value: "contextId", Usage: "only items that start under this context"},
+				{Name: "--all", Short: "-a", Usage: "include blocked and snoozed items"},
+			},
+			Subcommands: queueCommands,
+			Run:         func(env *commandEnv, args []string) string { return listQueue(env.qClient, env.ctxClient, args) }},
+		{Name: "doc", Aliases: []string{"d"}, Summary: "edit, sync and render the docs for contexts (edit by default)",
+			Subcommands: []*command{
+				{Name: "edit", Aliases: []string{"e"}, Summary: "edit the doc for the current context, creating it if needed",
+					Run: withSub("edit", docRun)},
+				{Name: "open", Aliases: []string{"o"}, Summary: "open the doc for the current context or its closest parent",
+					Run: withSub("open", docRun)},
+				{Name: "sync", Aliases: []string{"s"}, Summary: "pull and push the docs repo",
+					Flags: []commandFlag{{Name: "--background", Usage: "used by the sync started after editing"}},
+					Run:   withSub("sync", docRun)},
+				{Name: "status", Aliases: []string{"st"}, Summary: "show how the last sync went",
+					Run: withSub("status", docRun)},
+				{Name: "render", Aliases: []string{"r"}, Args: "[contextId]", Summary: "write the docs under a context as a static site",
+					Flags: []commandFlag{{Name: "--out", Short: "-o", Value: "dir", Usage: "where to write the site (default ctx-docs)"}},
+					Run:   withSub("render", docRun)},
+				{Name: "link", Aliases: []string{"l"}, Summary: "link an existing doc to the current context (not implemented)",
+					Run: withSub("link", docRun)},
+			},
+			Run: docRun},
+	}
+	help := &command{Name: "help", Args: "[command [subcommand]]", Summary: "show help for ctx or a command"}
+	help.Run = func(env *commandEnv, args []string) string {
+		return helpCmd(commands, args)
+	}
+	return append(commands, help)
+}
+
+func noteRun(env *commandEnv, args []string) string {
+	return noteCtx(env.ctxClient, env.current, args)
+}
+
+func checkRun(env *commandEnv, args []string) string {
+	return checkCmd(env.ctxClient, env.current, args)
+}
+
+func goalRun(env *commandEnv, args []string) string {
+	return goalCmd(env.ctxClient, args)
+}
+
+func docRun(env *commandEnv, args []string) string {
+	return docCmd(env.ctxClient, env.current, args)
+}
+
+func withSub(name string, run func(env *commandEnv, args []string) string) func(env *commandEnv, args []string) string {
+	return func(env *commandEnv, args []string) string {
+		return run(env, append([]string{name}, args...))
+	}
+}
+
+func (c *command) names() []string {
+	return append([]string{c.Name}, c.Aliases...)
+}
+
+func findCommand(commands []*command, name string) *command {
+	for _, c := range commands {
+		if containsString(c.names(), name) {
+			return c
+		}
+	}
+	return nil
+}
+
+func resolveCommand(commands []*command, args []string) (*command, []string, string) {
+	cmd := findCommand(commands, args[0])
+	if cmd == nil {
+		usageError(fmt.Sprintf("unknown command '%s'", args[0]), suggest(args[0], commands), "ctx help")
+	}
+	args = args[1:]
+	path := cmd.Name
+	// an item in the queue is never named like a flag, so ctx q -a is the list
+	if len(cmd.Subcommands) > 0 && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub := findCommand(cmd.Subcommands, args[0])
+		if sub == nil && cmd.Text {
+			return cmd, args, path
+		}
+		if sub == nil {
+			usageError(fmt.Sprintf("unknown %s command '%s'", path, args[0]), suggest(args[0], cmd.Subcommands), "ctx help "+path)
+		}
+		return sub, args[1:], path + " " + sub.Name
+	}
+	return cmd, args, path
+}
+
+func runCommand(cmd *command, env *commandEnv, args []string) string {
+	if cmd.Reports {
+		return withReports(env.ctxClient, env.qClient, func() string {
+			return cmd.Run(env, args)
+		})
+	}
+	return cmd.Run(env, args)
+}
+
+func wantsHelp(args []string) bool {
+	return containsString(args, "--help") || containsString(args, "-h")
+}
+
+func checkFlags(cmd *command, path string, args []string) []string {
+	known := []string{}
+	for _, f := range cmd.Flags {
+		known = append(known, f.Name)
+	}
+	// only up to the first argument that isn't a flag, so text can start with
+	// dashes. short flags are left alone since - on its own means stdin
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(args[:i:i], args[i+1:]...)
+		}
+		if !strings.HasPrefix(arg, "-") || strings.Contains(arg, " ") {
+			return args
+		}
+		if f := findFlag(cmd.Flags, arg); f != nil {
+			if f.Value != "" {
+				i++
+			}
+			continue
+		}
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		suggestion := ""
+		if close := closest(arg, known); len(close) > 0 {
+			suggestion = close[0]
+		}
+		usageError(fmt.Sprintf("unknown flag '%s' for ctx %s", arg, path), suggestion, fmt.Sprintf("ctx %s --help", path))
+	}
+	return args
+}
+
+func findFlag(flags []commandFlag, arg string) *commandFlag {
+	for i, f := range flags {
+		if arg == f.Name || (f.Short != "" && arg == f.Short) {
+			return &flags[i]
+		}
+	}
+	return nil
+}
+
+func usageError(message, suggestion, help string) {
+	fmt.Printf("Error: %s\n", message)
+	if suggestion != "" {
+		fmt.Printf("did you mean %s?\n", suggestion)
+	}
+	fmt.Printf("run %s for usage\n", help)
+	os.Exit(exitUsage)
+}
+
+func suggest(name string, commands []*command) string {
+	candidates := []string{}
+	for _, c := range commands {
+		candidates = append(candidates, c.Name)
+	}
+	matches := closest(name, candidates)
+	if len(matches) == 0 {
+		// an alias might be closer, but suggest the full name
+		for _, c := range commands {
+			if len(closest(name, c.Aliases)) > 0 {
+				return c.Name
+			}
+		}
+		return ""
+	}
+	return strings.Join(matches, " or ")
+}
+
+func closest(name string, candidates []string) []string {
+	type match struct {
+		name     string
+		distance int
+	}
+	matches := []match{}
+	for _, candidate := range candidates {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		limit := 2
+		if len(name) <= 3 {
+			limit = 1
+		}
+		if distance <= limit || (len(name) >= 2 && strings.HasPrefix(candidate, name)) {
+			matches = append(matches, match{candidate, distance})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+	names := []string{}
+	for i, m := range matches {
+		if i == 3 {
+			break
+		}
+		names = append(names, m.name)
+	}
+	return names
+}
+
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+func helpCmd(commands []*command, args []string) string {
+	if len(args) == 0 {
+		return helpAll(commands)
+	}
+	cmd, rest, path := resolveCommand(commands, args)
+	if len(rest) > 0 {
+		usageError(fmt.Sprintf("unknown %s command '%s'", path, rest[0]), suggest(rest[0], cmd.Subcommands), "ctx help "+path)
+	}
+	return helpCommand(cmd, path)
+}
+
+func helpAll(commands []*command) string {
+	output := "usage: ctx [command] [args]\n\nctx on its own shows the current context\n\ncommands:\n"
+	output += helpList(commands)
+	output += "\nrun ctx help <command> (or ctx <command> --help) for more on a command"
+	return output
+}
+
+func helpList(commands []*command) string {
+	output := ""
+	for _, c := range commands {
+		output += fmt.Sprintf("  %-18s %s\n", strings.Join(c.names(), ", "), c.Summary)
+	}
+	return output
+}
+
+func helpCommand(cmd *command, path string) string {
+	usage := "usage: ctx " + path
+	if len(cmd.Flags) > 0 {
+		usage += " [flags]"
+	}
+	if cmd.Args != "" {
+		usage += " " + cmd.Args
+	}
+	if len(cmd.Subcommands) > 0 {
+		usage += fmt.Sprintf("\n       ctx %s <command> [args]", path)
+	}
+	output := fmt.Sprintf("%s\n\n%s\n", usage, cmd.Summary)
+	if cmd.Details != "" {
+		output += cmd.Details + "\n"
+	}
+	if len(cmd.Aliases) > 0 {
+		output += fmt.Sprintf("\naliases: %s\n", strings.Join(cmd.Aliases, ", "))
+	}
+	if len(cmd.Flags) > 0 {
+		output += "\nflags:\n"
+		for _, f := range cmd.Flags {
+			name := f.Name
+			if f.Short != "" {
+				name += ", " + f.Short
+			}
+			if f.Value != "" {
+				name += " <" + f.Value + ">"
+			}
+			output += fmt.Sprintf("  %-32s %s\n", name, f.Usage)
+		}
+	}
+	if len(cmd.Subcommands) > 0 {
+		output += "\ncommands:\n" + helpList(cmd.Subcommands)
+		output += fmt.Sprintf("\nrun ctx help %s <command> for more on a command\n", path)
+	}
+	return strings.TrimRight(output, "\n")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"note", "note", 0},
+		{"", "goal", 4},
+		{"nte", "note", 1},
+		{"noet", "note", 1},
+		{"sumary", "summary", 1},
+		{"switchs", "switches", 1},
+		{"goal", "gaps", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	commands := newRegistry()
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"sumary", "summary"},
+		{"swich", "switch"},
+		{"stat", "stats"},
+		{"nte", "note"},
+		{"Resume", "resume"},
+		{"xyzzy", ""},
+	}
+	for _, tt := range tests {
+		got := suggest(tt.name, commands)
+		if tt.want == "" && got != "" {
+			t.Errorf("suggest(%q) = %q, want nothing", tt.name, got)
+		}
+		if tt.want != "" && !strings.HasPrefix(got, tt.want) {
+			t.Errorf("suggest(%q) = %q, want %q first", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveCommand(t *testing.T) {
+	commands := newRegistry()
+	tests := []struct {
+		args []string
+		path string
+		rest []string
+	}{
+		{[]string{"s", "thing"}, "switch", []string{"thing"}},
+		{[]string{"q", "-a"}, "q", []string{"-a"}},
+		{[]string{"q", "a", "--due", "friday"}, "q add", []string{"--due", "friday"}},
+		{[]string{"doc"}, "doc", []string{}},
+		{[]string{"doc", "r", "-o", "site"}, "doc render", []string{"-o", "site"}},
+		{[]string{"check", "d", "2"}, "check done", []string{"2"}},
+		{[]string{"goal", "rm", "work"}, "goal remove", []string{"work"}},
+		{[]string{"note", "rm", "1"}, "note remove", []string{"1"}},
+		{[]string{"note", "fixed", "the", "build"}, "note", []string{"fixed", "the", "build"}},
+	}
+	for _, tt := range tests {
+		_, rest, path := resolveCommand(commands, tt.args)
+		if path != tt.path || !reflect.DeepEqual(rest, tt.rest) {
+			t.Errorf("resolveCommand(%q) = %q %q, want %q %q", tt.args, path, rest, tt.path, tt.rest)
+		}
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	commands := newRegistry()
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-"}, []string{"-"}},
+		{[]string{"--edit", "-e"}, []string{"--edit", "-e"}},
+		{[]string{"--kv", "--not-a-flag", "text"}, []string{"--kv", "--not-a-flag", "text"}},
+		{[]string{"--force push"}, []string{"--force push"}},
+		{[]string{"text", "--force"}, []string{"text", "--force"}},
+		{[]string{"--", "--force"}, []string{"--force"}},
+		{[]string{"-e", "--", "--force", "--"}, []string{"-e", "--force", "--"}},
+	}
+	for _, tt := range tests {
+		cmd, args, path := resolveCommand(commands, append([]string{"note"}, tt.args...))
+		if got := checkFlags(cmd, path, args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("checkFlags(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
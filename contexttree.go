@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+// contextNode groups resumed copies of a context by name and parent, like
+// summary does
+type contextNode struct {
+	Name     string
+	Contexts []ctxclient.Context
+	Children []*contextNode
+	Parent   *contextNode
+	Minutes  float64
+}
+
+func contextsByParent(cs []ctxclient.Context) map[string][]ctxclient.Context {
+	byParent := map[string][]ctxclient.Context{}
+	for _, c := range cs {
+		if c.ParentId != "" {
+			byParent[c.ParentId] = append(byParent[c.ParentId], c)
+		}
+	}
+	return byParent
+}
+
+func addContextChildren(n *contextNode, byParent map[string][]ctxclient.Context) {
+	setContextNode(n)
+	children := map[string]*contextNode{}
+	for _, c := range n.Contexts {
+		for _, child := range byParent[c.ContextId] {
+			kid, ok := children[child.Name]
+			if !ok {
+				kid = &contextNode{Name: child.Name, Parent: n}
+				children[child.Name] = kid
+				n.Children = append(n.Children, kid)
+			}
+			kid.Contexts = append(kid.Contexts, child)
+		}
+	}
+	for _, kid := range n.Children {
+		addContextChildren(kid, byParent)
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Contexts[0].Created < n.Children[j].Contexts[0].Created
+	})
+}
+
+func setContextNode(n *contextNode) {
+	sort.Slice(n.Contexts, func(i, j int) bool {
+		return n.Contexts[i].Created < n.Contexts[j].Created
+	})
+	n.Minutes = 0
+	for _, c := range n.Contexts {
+		n.Minutes += contextMinutes(&c)
+	}
+}
+
+func contextTreeMinutes(n *contextNode) float64 {
+	total := n.Minutes
+	for _, kid := range n.Children {
+		total += contextTreeMinutes(kid)
+	}
+	return total
+}
+
+func contextLineage(ctxClient *ctxclient.ContextClient, contexts []ctxclient.Context) map[string][]ctxclient.Context {
+	byId := map[string]ctxclient.Context{}
+	for _, c := range contexts {
+		byId[c.ContextId] = c
+	}
+	lineages := map[string][]ctxclient.Context{}
+	for _, c := range contexts {
+		// c first, root last
+		lineage := []ctxclient.Context{c}
+		current := c
+		// guard against parent loops
+		for i := 0; current.ParentId != "" && i < 100; i++ {
+			parent, ok := byId[current.ParentId]
+			if !ok {
+				fetched, err := ctxClient.GetContext(current.ParentId)
+				if err != nil || fetched.ContextId == "" {
+					break
+				}
+				parent = *fetched
+				byId[parent.ContextId] = parent
+			}
+			lineage = append(lineage, parent)
+			current = parent
+		}
+		lineages[c.ContextId] = lineage
+	}
+	return lineages
+}
+
+func contextMinutes(c *ctxclient.Context) float64 {
+	created, err := time.Parse(ctxclient.SkDateFormat, c.Created)
+	if err != nil {
+		return 0
+	}
+	completed := time.Now().UTC()
+	if c.Completed != "" {
+		completed, err = time.Parse(ctxclient.SkDateFormat, c.Completed)
+		if err != nil {
+			return 0
+		}
+	}
+	return completed.Sub(created).Minutes() - pausedMinutes(c.Notes, created, completed)
+}
+
+func formatMinutes(minutes float64) string {
+	m := int(minutes + 0.5)
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh %dm", m/60, m%60)
+}
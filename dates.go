@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+func parseDate(s string, now time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	today := startOfDay(now)
+	if t, err := time.ParseInLocation(dateFormat, s, now.Location()); err == nil {
+		return t, nil
+	}
+	switch s {
+	case "today", "tonight", "eod":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "tomorrow", "tmr", "tmrw":
+		return today.AddDate(0, 0, 1), nil
+	case "next week":
+		return nextWeekday(today, time.Monday), nil
+	case "eow", "end of week":
+		if today.Weekday() == time.Friday {
+			return today, nil
+		}
+		return nextWeekday(today, time.Friday), nil
+	case "next month":
+		return time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()), nil
+	case "eom", "end of month":
+		return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location()), nil
+	}
+	if day, ok := weekdays[strings.TrimPrefix(s, "next ")]; ok {
+		return nextWeekday(today, day), nil
+	}
+	if n, unit, ok := parseCount(strings.TrimPrefix(s, "in ")); ok {
+		return addUnits(today, n, unit), nil
+	}
+	for _, layout := range []string{"Jan 2", "January 2", "2 Jan", "2 January", "Jan 2 2006", "January 2 2006", "1/2", "1/2/2006"} {
+		t, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") {
+			t = time.Date(today.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+			// a month and day without a year is the next one of those
+			if t.Before(today) {
+				t = t.AddDate(1, 0, 0)
+			}
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("can't read date '%s', try something like friday, tomorrow, in 3 days, 2w, jan 5 or %s", s, today.Format(dateFormat))
+}
+
+func parseCount(s string) (int, string, bool) {
+	re := regexp.MustCompile(`^(\d+)\s*([a-zA-Z]+)$`)
+	match := re.FindStringSubmatch(s)
+	if match == nil {
+		return 0, "", false
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, "", false
+	}
+	unit := match[2]
+	switch strings.ToLower(unit) {
+	case "d", "day", "days":
+		unit = "d"
+	case "w", "wk", "wks", "week", "weeks":
+		unit = "w"
+	case "month", "months", "mo", "mos":
+		unit = "M"
+	case "y", "yr", "yrs", "year", "years":
+		unit = "y"
+	}
+	// M is months, lowercase m is minutes and doesn't make sense for a day
+	if !containsString([]string{"d", "w", "M", "y"}, unit) {
+		return 0, "", false
+	}
+	return n, unit, true
+}
+
+func addUnits(t time.Time, n int, unit string) time.Time {
+	switch unit {
+	case "w":
+		return t.AddDate(0, 0, 7*n)
+	case "M":
+		return t.AddDate(0, n, 0)
+	case "y":
+		return t.AddDate(n, 0, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+func parseLength(length string) (time.Duration, error) {
+	if minutes, err := strconv.Atoi(length); err == nil {
+		length = fmt.Sprintf("%dm", minutes)
+	}
+	d, err := time.ParseDuration(length)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid length '%s'", length)
+	}
+	return d, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func nextWeekday(t time.Time, day time.Weekday) time.Time {
+	days := (int(day) - int(t.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return t.AddDate(0, 0, days)
+}
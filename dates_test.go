@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	// a wednesday
+	now := time.Date(2024, time.March, 13, 15, 4, 0, 0, time.Local)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2024-05-01", "2024-05-01"},
+		{"today", "2024-03-13"},
+		{"tomorrow", "2024-03-14"},
+		{"yesterday", "2024-03-12"},
+		{"friday", "2024-03-15"},
+		{"next wed", "2024-03-20"},
+		{"next week", "2024-03-18"},
+		{"eow", "2024-03-15"},
+		{"eom", "2024-03-31"},
+		{"next month", "2024-04-01"},
+		{"in 3 days", "2024-03-16"},
+		{"2w", "2024-03-27"},
+		{"1 month", "2024-04-13"},
+		{"jan 5", "2025-01-05"},
+		{"january 5", "2025-01-05"},
+		{"5 jan", "2025-01-05"},
+		{"Feb 14", "2025-02-14"},
+		{"february 14 2024", "2024-02-14"},
+		{"mar 13", "2024-03-13"},
+		{"march 12", "2025-03-12"},
+		{"apr 1", "2024-04-01"},
+		{"april 30", "2024-04-30"},
+		{"may 9", "2024-05-09"},
+		{"9 may", "2024-05-09"},
+		{"jun 2", "2024-06-02"},
+		{"2 june", "2024-06-02"},
+		{"jul 4", "2024-07-04"},
+		{"july 4 2025", "2025-07-04"},
+		{"aug 20", "2024-08-20"},
+		{"AUGUST 20", "2024-08-20"},
+		{"sep 1", "2024-09-01"},
+		{"1 september", "2024-09-01"},
+		{"oct 31", "2024-10-31"},
+		{"october 31 2023", "2023-10-31"},
+		{"nov 5", "2024-11-05"},
+		{"november 5", "2024-11-05"},
+		{"dec 25", "2024-12-25"},
+		{"25 december", "2024-12-25"},
+		{"12/25", "2024-12-25"},
+		{"1/2/2025", "2025-01-02"},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.in, now)
+		if err != nil {
+			t.Errorf("parseDate(%q): %v", tt.in, err)
+			continue
+		}
+		if got.Format(dateFormat) != tt.want {
+			t.Errorf("parseDate(%q) = %s, want %s", tt.in, got.Format(dateFormat), tt.want)
+		}
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	now := time.Date(2024, time.March, 13, 0, 0, 0, 0, time.Local)
+	for _, in := range []string{"", "someday", "jan 32", "13/1", "in 3 minutes"} {
+		if _, err := parseDate(in, now); err == nil {
+			t.Errorf("parseDate(%q) should fail", in)
+		}
+	}
+}
@@ -0,0 +1,617 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type docSyncStatus struct {
+	LastAttempt string `json:"lastAttempt,omitempty"`
+	LastSuccess string `json:"lastSuccess,omitempty"`
+	LastPull    string `json:"lastPull,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+	InProgress  bool   `json:"inProgress,omitempty"`
+	// a docs branch that couldn't be merged into main and needs merging by hand
+	MergeConflict string `json:"mergeConflict,omitempty"`
+}
+
+func docCmd(ctxClient *ctxclient.ContextClient, current *ctxclient.Context, args []string) string {
+	output := ""
+	if CTX_DOCS_PATH == "" {
+		return "CTX_DOCS_PATH environment variable not set"
+	}
+	cmd := "e"
+	if len(args) > 0 {
+		cmd = args[0]
+		args = args[1:]
+	}
+	switch cmd {
+	case "s", "sync":
+		// --background is used by the detached sync started after editing,
+		// it respects CTX_DOCS_SYNC_EVERY instead of always pulling
+		force := !(len(args) > 0 && args[0] == "--background")
+		err := syncNotes(force)
+		if err == errDocSyncRunning {
+			return "another docs sync is running, skipped"
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		output = "notes synced"
+	case "st", "status":
+		output = docStatus()
+	case "e", "edit":
+		switchDocsBranch(ctxClient, current)
+		dateString := strings.Split(current.ContextId, "T")[0]
+		dateSlice := strings.Split(dateString, "-")
+		year := dateSlice[0]
+		month := dateSlice[1]
+		day := dateSlice[2]
+		dirPath := fmt.Sprintf("ctx/%s/%s/%s", year, month, day)
+		fileName := fmt.Sprintf("%s.md", strings.ReplaceAll(current.Name, " ", "_"))
+		absolutePath := fmt.Sprintf("%s/%s/%s", CTX_DOCS_PATH, dirPath, fileName)
+		if current.Document.RealtivePath == "" {
+			// create new doc
+			fmt.Println("creating new doc")
+			// first check if file exists
+			_, err := os.Stat(absolutePath)
+			if err != nil {
+				err := os.MkdirAll(fmt.Sprintf("%s/%s", CTX_DOCS_PATH, dirPath), 0755)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(exitError)
+				}
+				docString := ""
+				if current.ParentId != "" {
+					parentDoc, err := findParentDoc(ctxClient, current.ParentId)
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
+						os.Exit(exitError)
+					}
+					if parentDoc.RealtivePath != "" {
+						docString = fmt.Sprintf("[parent doc](%s)\n", parentDoc.RealtivePath)
+					}
+				}
+				err = os.WriteFile(absolutePath, []byte(docString), 0644)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(exitError)
+				}
+			}
+			current.Document.RealtivePath = fmt.Sprintf("%s/%s", dirPath, fileName)
+			if CTX_GITHUB_DOCS_URL != "" {
+				current.Document.Github = fmt.Sprintf("%s/%s", CTX_GITHUB_DOCS_URL, current.Document.RealtivePath)
+			}
+			_, err = ctxClient.UpdateContext(current)
+			if err != nil {
+				fmt.Printf("Error adding doc to context: %v\n", err)
+				os.Exit(exitError)
+			}
+		}
+		// edit existing doc
+		output = fmt.Sprintf("opening doc:\n%s", absolutePath)
+		openDoc(current.Document.RealtivePath)
+	case "r", "render":
+		output = renderDocs(ctxClient, current, args)
+	case "l", "link":
+		output = "not implemented"
+		// link doc to context
+		// if a doc is already linked, prompt to start new ctx
+	case "o", "open":
+		switchDocsBranch(ctxClient, current)
+		realtivePath := current.Document.RealtivePath
+		// open doc in editor
+		// if none, open closest parent
+		// if no parent, return saying none found and prompt to create new
+		if realtivePath == "" {
+			parentDoc, err := findParentDoc(ctxClient, current.ContextId)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitError)
+			}
+			if parentDoc.RealtivePath != "" {
+				realtivePath = parentDoc.RealtivePath
+			}
+		}
+		if realtivePath == "" {
+			output = "no related docs found. create one with\nctx doc edit"
+		} else {
+			output = fmt.Sprintf("opening doc: %s", realtivePath)
+			openDoc(realtivePath)
+		}
+	default:
+		usageError(fmt.Sprintf("unknown doc command '%s'", cmd), "", "ctx help doc")
+	}
+	return output
+}
+
+func openDoc(realtivePath string) {
+	absolutePath := fmt.Sprintf("%s/%s", CTX_DOCS_PATH, realtivePath)
+	if _, err := os.Stat(absolutePath); err != nil {
+		fmt.Println("doc not found locally, syncing first")
+		err := syncNotes(true)
+		if err != nil && err != errDocSyncRunning {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	err := openInEditor(absolutePath, 0)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	err = syncNotesInBackground()
+	if err != nil {
+		fmt.Printf("Error starting doc sync: %v\n", err)
+	}
+}
+
+func findParentDoc(ctxClient *ctxclient.ContextClient, ctxId string) (ctxclient.Document, error) {
+	doc := ctxclient.Document{}
+	c, err := ctxClient.GetContext(ctxId)
+	if err != nil {
+		return doc, err
+	}
+	if c.Document.RealtivePath != "" {
+		return c.Document, nil
+	}
+	if c.ParentId == "" {
+		return doc, nil
+	}
+	return findParentDoc(ctxClient, c.ParentId)
+}
+
+func syncNotesInBackground() error {
+	err := os.MkdirAll(CTX_STATE_DIR, 0755)
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(CTX_STATE_DIR, "docs-sync.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	syncCmd := exec.Command(self, "doc", "sync", "--background")
+	syncCmd.Stdout = logFile
+	syncCmd.Stderr = logFile
+	err = syncCmd.Start()
+	if err != nil {
+		return err
+	}
+	return syncCmd.Process.Release()
+}
+
+func syncNotes(force bool) error {
+	status, err := readDocSyncStatus()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	pull := force || status.LastSuccess == ""
+	if !pull {
+		every, err := time.ParseDuration(CTX_DOCS_SYNC_EVERY)
+		if err != nil {
+			return fmt.Errorf("invalid CTX_DOCS_SYNC_EVERY '%s': %v", CTX_DOCS_SYNC_EVERY, err)
+		}
+		lastSuccess, err := time.Parse(ctxclient.SkDateFormat, status.LastSuccess)
+		pull = err != nil || now.Sub(lastSuccess) >= every
+	}
+	unlock, err := lockDocSync()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	status.LastAttempt = now.Format(ctxclient.SkDateFormat)
+	err = writeDocSyncStatus(status)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("syncing:\n%s\n", CTX_DOCS_PATH)
+	err = pushPullNotes(pull)
+	if err != nil {
+		status.LastError = err.Error()
+		writeDocSyncStatus(status)
+		return err
+	}
+	status.LastError = ""
+	status.LastSuccess = time.Now().UTC().Format(ctxclient.SkDateFormat)
+	if pull {
+		status.LastPull = status.LastSuccess
+	}
+	return writeDocSyncStatus(status)
+}
+
+func pushPullNotes(pull bool) error {
+	repo, err := git.PlainOpen(CTX_DOCS_PATH)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	// commit first so the pull doesn't trip over a dirty worktree
+	err = commitNotes(worktree)
+	if err != nil {
+		return err
+	}
+
+	if pull {
+		head, err := repo.Head()
+		if err != nil {
+			return err
+		}
+		err = worktree.Pull(&git.PullOptions{
+			RemoteName:    "origin",
+			ReferenceName: head.Name(),
+			Progress:      os.Stdout,
+		})
+		// a docs branch that was never pushed has nothing to pull yet
+		if err != nil && err != git.NoErrAlreadyUpToDate && err != plumbing.ErrReferenceNotFound {
+			return fmt.Errorf("pulling changes: %v", err)
+		}
+	}
+
+	err = repo.Push(&git.PushOptions{
+		RemoteName: "origin",
+		Progress:   os.Stdout,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("pushing changes: %v", err)
+	}
+	return nil
+}
+
+func commitNotes(worktree *git.Worktree) error {
+	_, err := worktree.Add(".")
+	if err != nil {
+		return err
+	}
+	_, err = worktree.Commit("sync notes", &git.CommitOptions{})
+	if err != nil && err != git.ErrEmptyCommit {
+		return fmt.Errorf("committing changes: %v", err)
+	}
+	return nil
+}
+
+func docsBranchesEnabled() bool {
+	return CTX_DOCS_PATH != "" && CTX_DOCS_BRANCHES == "true"
+}
+
+func docsBranchName(ctxClient *ctxclient.ContextClient, c *ctxclient.Context) (string, error) {
+	root := c
+	for root.ParentId != "" {
+		parent, err := ctxClient.GetContext(root.ParentId)
+		if err != nil {
+			return "", err
+		}
+		root = parent
+	}
+	// named after the root, not its id, so a resumed root keeps its branch
+	slug := docSlug(root.Name)
+	if slug == "" {
+		slug = getLastHash(root.ContextId)
+		slug = strings.NewReplacer(":", "-", "T", "-", "Z", "").Replace(slug)
+	}
+	return "ctx/" + slug, nil
+}
+
+func switchDocsBranch(ctxClient *ctxclient.ContextClient, c *ctxclient.Context) {
+	if !docsBranchesEnabled() {
+		return
+	}
+	branch, err := docsBranchName(ctxClient, c)
+	if err == nil {
+		err = checkoutDocsBranch(branch)
+	}
+	if err != nil {
+		fmt.Printf("Error switching docs branch: %v\n", err)
+	}
+}
+
+func checkoutDocsBranch(branch string) error {
+	repo, err := git.PlainOpen(CTX_DOCS_PATH)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	if head.Name() == branchRef {
+		return nil
+	}
+	err = commitNotes(worktree)
+	if err != nil {
+		return err
+	}
+	opts := &git.CheckoutOptions{Branch: branchRef}
+	_, err = repo.Reference(branchRef, true)
+	if err == plumbing.ErrReferenceNotFound {
+		// start from the remote branch if another machine pushed it,
+		// otherwise branch off main
+		start, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+		if err == plumbing.ErrReferenceNotFound {
+			start, err = repo.Reference(plumbing.NewBranchReferenceName(CTX_DOCS_MAIN_BRANCH), true)
+		}
+		if err != nil {
+			return err
+		}
+		opts.Create = true
+		opts.Hash = start.Hash()
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("checking out docs branch: %s\n", branch)
+	return worktree.Checkout(opts)
+}
+
+func mergeDocsBranch(ctxClient *ctxclient.ContextClient, c *ctxclient.Context) {
+	if !docsBranchesEnabled() || c.Name == "" {
+		return
+	}
+	// a sub context closing doesn't finish the root's docs
+	if c.ParentId != "" && docsTreeOpen(ctxClient, c) {
+		return
+	}
+	branch, err := docsBranchName(ctxClient, c)
+	if err == nil {
+		err = mergeDocsBranchIntoMain(branch)
+	}
+	if err != nil {
+		fmt.Printf("Error merging docs branch: %v\n", err)
+		recordDocsMerge(branch, err)
+		return
+	}
+	recordDocsMerge(branch, nil)
+	err = syncNotesInBackground()
+	if err != nil {
+		fmt.Printf("Error starting doc sync: %v\n", err)
+	}
+}
+
+func docsTreeOpen(ctxClient *ctxclient.ContextClient, c *ctxclient.Context) bool {
+	for c.ParentId != "" {
+		parent, err := ctxClient.GetContext(c.ParentId)
+		if err != nil || parent.ContextId == "" {
+			return false
+		}
+		if parent.Completed == "" {
+			return true
+		}
+		c = parent
+	}
+	return false
+}
+
+func mergeDocsBranchIntoMain(branch string) error {
+	repo, err := git.PlainOpen(CTX_DOCS_PATH)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	branchRef, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	if head.Name() == branchRef.Name() {
+		err = commitNotes(worktree)
+		if err != nil {
+			return err
+		}
+		// re-read the branch in case committing moved it
+		branchRef, err = repo.Reference(branchRef.Name(), true)
+		if err != nil {
+			return err
+		}
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(CTX_DOCS_MAIN_BRANCH),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("merging docs branch %s into %s\n", branch, CTX_DOCS_MAIN_BRANCH)
+	err = repo.Merge(*branchRef, git.MergeOptions{Strategy: git.FastForwardMerge})
+	// go-git only fast-forwards, the git cli merges once main has moved on
+	if err == git.ErrFastForwardMergeNotPossible {
+		output, err := exec.Command("git", "-C", CTX_DOCS_PATH, "merge", "--no-edit", branch).CombinedOutput()
+		if err != nil {
+			// leave main as it was so later syncs still work
+			exec.Command("git", "-C", CTX_DOCS_PATH, "merge", "--abort").Run()
+			return fmt.Errorf("couldn't merge %s into %s, merge it by hand: %v\n%s", branch, CTX_DOCS_MAIN_BRANCH, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Merge only moves the ref, bring the worktree along with it
+	return worktree.Reset(&git.ResetOptions{Commit: branchRef.Hash(), Mode: git.HardReset})
+}
+
+func docStatus() string {
+	status, err := readDocSyncStatus()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	status.InProgress = docSyncLocked()
+	output, err := stringifyDocSyncStatus(&status)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if status.LastSuccess == "" {
+		return output + "\nnotes have not been synced yet"
+	}
+	lastSuccess, err := time.Parse(ctxclient.SkDateFormat, status.LastSuccess)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	minutes := time.Now().UTC().Sub(lastSuccess).Minutes()
+	return output + fmt.Sprintf("\nlast synced %d minutes ago", int(minutes+0.5))
+}
+
+var errDocSyncRunning = errors.New("another docs sync is running")
+
+// a sync that was killed leaves its lock behind, so an old lock is taken over
+const docSyncLockStale = 10 * time.Minute
+
+func docSyncLockPath() string {
+	return filepath.Join(CTX_STATE_DIR, "docs-sync.lock")
+}
+
+func docSyncLocked() bool {
+	info, err := os.Stat(docSyncLockPath())
+	return err == nil && time.Since(info.ModTime()) < docSyncLockStale
+}
+
+func lockDocSync() (func(), error) {
+	err := os.MkdirAll(CTX_STATE_DIR, 0755)
+	if err != nil {
+		return nil, err
+	}
+	path := docSyncLockPath()
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if docSyncLocked() {
+			return nil, errDocSyncRunning
+		}
+		os.Remove(path)
+	}
+	return nil, errDocSyncRunning
+}
+
+func backgroundDocSync() string {
+	if CTX_DOCS_PATH == "" {
+		return "CTX_DOCS_PATH environment variable not set"
+	}
+	err := syncNotes(false)
+	if err == errDocSyncRunning {
+		return "another docs sync is running, skipped"
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return "notes synced"
+}
+
+func recordDocsMerge(branch string, mergeErr error) {
+	status, err := readDocSyncStatus()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if mergeErr == nil {
+		if status.MergeConflict != branch {
+			return
+		}
+		status.MergeConflict = ""
+	} else {
+		status.MergeConflict = branch
+		status.LastError = mergeErr.Error()
+		logDocSync(fmt.Sprintf("merging docs branch: %v", mergeErr))
+	}
+	err = writeDocSyncStatus(status)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func logDocSync(message string) {
+	err := os.MkdirAll(CTX_STATE_DIR, 0755)
+	if err != nil {
+		return
+	}
+	logFile, err := os.OpenFile(filepath.Join(CTX_STATE_DIR, "docs-sync.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer logFile.Close()
+	fmt.Fprintf(logFile, "%s %s\n", time.Now().UTC().Format(ctxclient.SkDateFormat), message)
+}
+
+func docSyncStatusPath() string {
+	return filepath.Join(CTX_STATE_DIR, "docs-sync.json")
+}
+
+func readDocSyncStatus() (docSyncStatus, error) {
+	status := docSyncStatus{}
+	data, err := os.ReadFile(docSyncStatusPath())
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	err = json.Unmarshal(data, &status)
+	return status, err
+}
+
+func writeDocSyncStatus(status docSyncStatus) error {
+	err := os.MkdirAll(CTX_STATE_DIR, 0755)
+	if err != nil {
+		return err
+	}
+	data, err := jsonMarshalIndent(status, false)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(docSyncStatusPath(), data, 0644)
+}
+
+func stringifyDocSyncStatus(s *docSyncStatus) (string, error) {
+	sJson, err := jsonMarshalIndent(s, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return "", err
+	}
+	if EXPORT_TYPE == "yaml" {
+		return printYaml(sJson)
+	}
+	return string(sJson), nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type docNode struct {
+	*contextNode
+	Page     string
+	Doc      string
+	Children []*docNode
+	Parent   *docNode
+}
+
+type docPage struct {
+	Node     *docNode
+	Root     *docNode
+	Crumbs   []*docNode
+	Notes    []template.HTML
+	Time     string
+	Total    string
+	Doc      template.HTML
+	Rendered string
+}
+
+var docPageTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"nav": func(n *docNode, current *docNode) template.HTML {
+		return template.HTML(renderDocNav(n, current))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Node.Name}}</title>
+<style>
+body { font-family: sans-serif; margin: 0; display: flex; }
+nav { width: 18em; padding: 1em; border-right: 1px solid #ddd; min-height: 100vh; }
+nav ul { padding-left: 1em; }
+nav .current { font-weight: bold; }
+main { padding: 1em 2em; max-width: 50em; }
+.crumbs, .meta { color: #666; }
+pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<nav>{{nav .Root .Node}}</nav>
+<main>
+<p class="crumbs">{{range .Crumbs}}<a href="{{.Page}}">{{.Name}}</a> / {{end}}{{.Node.Name}}</p>
+<h1>{{.Node.Name}}</h1>
+<p class="meta">time spent: {{.Time}}{{if ne .Time .Total}} ({{.Total}} including sub contexts){{end}}</p>
+{{if .Notes}}<h2>notes</h2>
+<ul>{{range .Notes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Doc}}<h2>doc</h2>
+{{.Doc}}{{end}}
+{{if .Node.Children}}<h2>sub contexts</h2>
+<ul>{{range .Node.Children}}<li><a href="{{.Page}}">{{.Name}}</a></li>{{end}}</ul>{{end}}
+<p class="meta">rendered by ctx {{.Rendered}}</p>
+</main>
+</body>
+</html>
+`))
+
+func renderDocs(ctxClient *ctxclient.ContextClient, current *ctxclient.Context, args []string) string {
+	contextId := ""
+	outDir := "ctx-docs"
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--out" || args[i] == "-o" {
+			if i+1 >= len(args) {
+				fmt.Printf("Error: missing directory for %s\n", args[i])
+				os.Exit(exitUsage)
+			}
+			outDir = args[i+1]
+			i++
+		} else {
+			contextId = args[i]
+		}
+	}
+	target := current
+	if contextId != "" {
+		c, err := ctxClient.GetContext(contextId)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		target = c
+	}
+	if target.ContextId == "" {
+		return "no context to render"
+	}
+
+	root, node, err := buildDocTree(ctxClient, target)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	nodes := flattenDocTree(root)
+	node.Page = "index.html"
+	pages := map[string]string{}
+	for i, n := range nodes {
+		if n.Page == "" {
+			n.Page = fmt.Sprintf("%d-%s.html", i, docSlug(n.Name))
+		}
+		if n.Doc != "" {
+			pages[n.Doc] = n.Page
+		}
+	}
+
+	err = os.MkdirAll(outDir, 0755)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	rendered := time.Now().Format("2006-01-02 15:04")
+	for _, n := range nodes {
+		page := docPage{
+			Node:     n,
+			Root:     root,
+			Notes:    docNodeNotes(n),
+			Time:     formatMinutes(n.Minutes),
+			Total:    formatMinutes(contextTreeMinutes(n.contextNode)),
+			Rendered: rendered,
+		}
+		for p := n.Parent; p != nil; p = p.Parent {
+			page.Crumbs = append([]*docNode{p}, page.Crumbs...)
+		}
+		if n.Doc != "" {
+			docHTML, err := renderMarkdownDoc(n.Doc, pages)
+			if err != nil {
+				fmt.Printf("Error rendering doc %s: %v\n", n.Doc, err)
+			}
+			page.Doc = docHTML
+		}
+		buffer := &bytes.Buffer{}
+		err := docPageTemplate.Execute(buffer, page)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		err = os.WriteFile(filepath.Join(outDir, n.Page), buffer.Bytes(), 0644)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	return fmt.Sprintf("rendered %d pages for '%s' to:\n%s", len(nodes), target.Name, filepath.Join(outDir, "index.html"))
+}
+
+func buildDocTree(ctxClient *ctxclient.ContextClient, target *ctxclient.Context) (*docNode, *docNode, error) {
+	node := &contextNode{Name: target.Name}
+	root := node
+	parentId := target.ParentId
+	for parentId != "" {
+		p, err := ctxClient.GetContext(parentId)
+		if err != nil {
+			return nil, nil, err
+		}
+		parent := &contextNode{Name: p.Name, Contexts: []ctxclient.Context{*p}, Children: []*contextNode{root}}
+		setContextNode(parent)
+		root.Parent = parent
+		root = parent
+		parentId = p.ParentId
+	}
+
+	created, err := time.Parse(ctxclient.SkDateFormat, target.Created)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the api can't list by parent, so descendants come from everything
+	// created since target
+	hours := int(math.Ceil(time.Now().UTC().Sub(created).Hours())) + 1
+	cs, err := ctxClient.ListContexts(ctxclient.QSParams{
+		"start": fmt.Sprint(hours),
+		"end":   "0",
+		"unit":  "h",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	node.Contexts = []ctxclient.Context{*target}
+	for _, c := range *cs {
+		// resumed copies of the target belong to the same node
+		if c.Name == target.Name && c.ParentId == target.ParentId && c.ContextId != target.ContextId {
+			node.Contexts = append(node.Contexts, c)
+		}
+	}
+	addContextChildren(node, contextsByParent(*cs))
+	pages := map[*contextNode]*docNode{}
+	return newDocNode(root, nil, pages), pages[node], nil
+}
+
+func newDocNode(n *contextNode, parent *docNode, pages map[*contextNode]*docNode) *docNode {
+	page := &docNode{contextNode: n, Parent: parent}
+	for _, c := range n.Contexts {
+		if c.Document.RealtivePath != "" {
+			page.Doc = c.Document.RealtivePath
+			break
+		}
+	}
+	pages[n] = page
+	for _, kid := range n.Children {
+		page.Children = append(page.Children, newDocNode(kid, page, pages))
+	}
+	return page
+}
+
+func flattenDocTree(n *docNode) []*docNode {
+	nodes := []*docNode{n}
+	for _, kid := range n.Children {
+		nodes = append(nodes, flattenDocTree(kid)...)
+	}
+	return nodes
+}
+
+func docNodeNotes(n *docNode) []template.HTML {
+	notes := []template.HTML{}
+	for _, c := range n.Contexts {
+		cNotes, err := readNotes(c.Notes)
+		if err != nil {
+			notes = append(notes, template.HTML(template.HTMLEscapeString(string(c.Notes))))
+			continue
+		}
+		for _, cNote := range cNotes {
+			notes = append(notes, noteHTML(cNote))
+		}
+	}
+	return notes
+}
+
+func noteHTML(n note) template.HTML {
+	html := noteBodyHTML(n)
+	if n.At != "" {
+		html += template.HTML(fmt.Sprintf(` <span class="meta">%s %s</span>`, template.HTMLEscapeString(n.At), template.HTMLEscapeString(n.Source)))
+	}
+	return html
+}
+
+func noteBodyHTML(n note) template.HTML {
+	switch n.Type {
+	case noteTypeLink:
+		title := n.Title
+		if title == "" {
+			title = n.URL
+		}
+		// the pages get shared, so only web links are clickable
+		if u, err := url.Parse(n.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return template.HTML(template.HTMLEscapeString(title))
+		}
+		return template.HTML(fmt.Sprintf(`<a href="%s">%s</a>`, template.HTMLEscapeString(n.URL), template.HTMLEscapeString(title)))
+	case noteTypeChecklist, noteTypeKV:
+		lines := strings.Split(template.HTMLEscapeString(noteString(n)), "\n")
+		return template.HTML(strings.Join(lines, "<br>"))
+	}
+	return template.HTML(fmt.Sprintf("<pre>%s</pre>", template.HTMLEscapeString(n.Text)))
+}
+
+func renderMarkdownDoc(realtivePath string, pages map[string]string) (template.HTML, error) {
+	data, err := os.ReadFile(filepath.Join(CTX_DOCS_PATH, realtivePath))
+	if err != nil {
+		return "", err
+	}
+	buffer := &bytes.Buffer{}
+	err = goldmark.Convert(data, buffer)
+	if err != nil {
+		return "", err
+	}
+	html := buffer.String()
+	for doc, page := range pages {
+		html = strings.ReplaceAll(html, fmt.Sprintf(`href="%s"`, doc), fmt.Sprintf(`href="%s"`, page))
+	}
+	return template.HTML(html), nil
+}
+
+func renderDocNav(n *docNode, current *docNode) string {
+	class := ""
+	if n == current {
+		class = ` class="current"`
+	}
+	nav := fmt.Sprintf(`<ul><li%s><a href="%s">%s</a>`, class, n.Page, template.HTMLEscapeString(n.Name))
+	for _, kid := range n.Children {
+		nav += renderDocNav(kid, current)
+	}
+	return nav + "</li></ul>"
+}
+
+func docSlug(name string) string {
+	re := regexp.MustCompile(`[^a-z0-9_-]+`)
+	return re.ReplaceAllString(strings.ToLower(strings.ReplaceAll(name, " ", "-")), "")
+}
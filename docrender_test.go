@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNoteBodyHTMLLinks(t *testing.T) {
+	tests := []struct {
+		url   string
+		title string
+		want  string
+	}{
+		{"https://example.com/a?b=1&c=2", "docs", `<a href="https://example.com/a?b=1&amp;c=2">docs</a>`},
+		{"http://example.com", "", `<a href="http://example.com">http://example.com</a>`},
+		{"javascript://host/%0aalert(1)", "click", "click"},
+		{"JavaScript://host/%0aalert(1)", "", "JavaScript://host/%0aalert(1)"},
+		{"data://host/text/html,<script>", "", "data://host/text/html,&lt;script&gt;"},
+	}
+	for _, tt := range tests {
+		got := string(noteBodyHTML(note{Type: noteTypeLink, URL: tt.url, Title: tt.title}))
+		if got != tt.want {
+			t.Errorf("noteBodyHTML(%q) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
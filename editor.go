@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// gui editors return right away unless told to wait for the file to close
+	waitFlags = map[string]string{
+		"code":          "--wait",
+		"code-insiders": "--wait",
+		"codium":        "--wait",
+		"cursor":        "--wait",
+		"subl":          "--wait",
+		"zed":           "--wait",
+		"atom":          "--wait",
+		"bbedit":        "--wait",
+		"mate":          "-w",
+		"gvim":          "-f",
+		"mvim":          "-f",
+	}
+)
+
+func editorArgs() []string {
+	for _, editor := range []string{CTX_DEFAULT_EDITOR, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if fields := strings.Fields(editor); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"code"}
+}
+
+func editorName(editor string) string {
+	return strings.TrimSuffix(filepath.Base(editor), ".exe")
+}
+
+func editorCommand(path string, line int) *exec.Cmd {
+	fields := editorArgs()
+	name := editorName(fields[0])
+	args := fields[1:]
+	if flag, ok := waitFlags[name]; ok && !containsString(args, flag) {
+		args = append(args, flag)
+	}
+	if line > 0 {
+		switch name {
+		case "code", "code-insiders", "codium", "cursor":
+			args = append(args, "--goto", fmt.Sprintf("%s:%d", path, line))
+		case "subl", "zed", "hx", "helix", "atom":
+			args = append(args, fmt.Sprintf("%s:%d", path, line))
+		case "mate", "bbedit":
+			args = append(args, "-l", fmt.Sprint(line), path)
+		default:
+			// +line is understood by vi, emacs, nano, micro and most others
+			args = append(args, fmt.Sprintf("+%d", line), path)
+		}
+	} else {
+		args = append(args, path)
+	}
+	cmd := exec.Command(fields[0], args...)
+	// terminal editors need the tty, gui editors ignore it
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func openInEditor(path string, line int) error {
+	return editorCommand(path, line).Run()
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type estimateEntry struct {
+	QueueId    string  `json:"queueId"`
+	Name       string  `json:"name"`
+	ContextId  string  `json:"contextId"`
+	Started    string  `json:"started"`
+	Estimate   string  `json:"estimate"`
+	Actual     string  `json:"actual"`
+	Ratio      float64 `json:"ratio"`
+	InProgress bool    `json:"inProgress,omitempty"`
+}
+
+type estimateReport struct {
+	Since    string          `json:"since"`
+	Items    []estimateEntry `json:"items"`
+	Estimate string          `json:"estimate"`
+	Actual   string          `json:"actual"`
+	Bias     string          `json:"bias"`
+}
+
+func queueEstimate(estimate string) string {
+	d, err := time.ParseDuration(estimate)
+	if err != nil || d <= 0 {
+		fmt.Printf("Error: invalid estimate '%s', use something like 45m, 2h or 1h30m\n", estimate)
+		os.Exit(exitUsage)
+	}
+	return estimate
+}
+
+func estimateMinutes(estimate string) float64 {
+	d, err := time.ParseDuration(estimate)
+	if err != nil {
+		return 0
+	}
+	return d.Minutes()
+}
+
+func estimateNote(qId, estimate string) string {
+	// the api doesn't list items that left the queue, accuracy finds them
+	// through this note on the context they started
+	encoded, err := encodeNote(note{
+		Type:   noteTypeKV,
+		At:     time.Now().UTC().Format(ctxclient.SkDateFormat),
+		Source: noteSourceQueue,
+		Values: map[string]string{"queue": qId, "estimate": estimate},
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return encoded
+}
+
+func estimateQueue(qClient *ctxclient.QueueClient, args []string) string {
+	if len(args) < 2 {
+		fmt.Printf("Error: use ctx q estimate <queueId> <duration|none>\n")
+		os.Exit(exitUsage)
+	}
+	q, err := qClient.GetQueue(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	meta := getQueueMeta(q)
+	if args[1] == "none" {
+		meta.Estimate = ""
+	} else {
+		meta.Estimate = queueEstimate(args[1])
+	}
+	setQueueMeta(q, meta)
+	_, err = qClient.UpdateQueue(q)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if meta.Estimate == "" {
+		return fmt.Sprintf("'%s' has no estimate", q.Name)
+	}
+	return fmt.Sprintf("'%s' is estimated at %s", q.Name, meta.Estimate)
+}
+
+func accuracyQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, args []string) string {
+	since, _ := flagValue(args, "--since")
+	if since == "" {
+		since = "30d"
+	}
+	start, unit, _, err := parseSince(since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	cs, err := ctxClient.ListContexts(ctxclient.QSParams{
+		"start": start,
+		"end":   "0",
+		"unit":  unit,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	byParent := contextsByParent(*cs)
+	byId := map[string]ctxclient.Context{}
+	qIds := []string{}
+	for _, c := range *cs {
+		byId[c.ContextId] = c
+		notes, err := readNotes(c.Notes)
+		if err != nil {
+			continue
+		}
+		for _, n := range notes {
+			qId := n.Values["queue"]
+			if n.Type == noteTypeKV && n.Source == noteSourceQueue && qId != "" && !containsString(qIds, qId) {
+				qIds = append(qIds, qId)
+			}
+		}
+	}
+
+	report := estimateReport{Since: since, Items: []estimateEntry{}}
+	totalEstimate, totalActual := 0.0, 0.0
+	for _, qId := range qIds {
+		q, err := qClient.GetQueue(qId)
+		if err != nil || q.ContextId == "" {
+			continue
+		}
+		estimated := estimateMinutes(getQueueMeta(q).Estimate)
+		linked, ok := byId[q.ContextId]
+		// there's nothing to compare a zero estimate with
+		if estimated <= 0 || !ok {
+			continue
+		}
+		node := &contextNode{Name: linked.Name}
+		inProgress := false
+		for _, c := range *cs {
+			// resumed copies share the name and parent
+			if c.ContextId == linked.ContextId || (c.Name == linked.Name && c.ParentId == linked.ParentId && c.Created > linked.Created) {
+				node.Contexts = append(node.Contexts, c)
+				inProgress = inProgress || c.Completed == ""
+			}
+		}
+		addContextChildren(node, byParent)
+		actual := contextTreeMinutes(node)
+		report.Items = append(report.Items, estimateEntry{
+			QueueId:    qId,
+			Name:       q.Name,
+			ContextId:  linked.ContextId,
+			Started:    q.Started,
+			Estimate:   formatMinutes(estimated),
+			Actual:     formatMinutes(actual),
+			Ratio:      math.Round(actual/estimated*100) / 100,
+			InProgress: inProgress,
+		})
+		// items still being worked on would skew the bias low
+		if !inProgress {
+			totalEstimate += estimated
+			totalActual += actual
+		}
+	}
+	if len(report.Items) == 0 {
+		return fmt.Sprintf("no estimated queue items started in the last %s", since)
+	}
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		return report.Items[i].Started < report.Items[j].Started
+	})
+	report.Estimate = formatMinutes(totalEstimate)
+	report.Actual = formatMinutes(totalActual)
+	report.Bias = estimateBias(totalEstimate, totalActual)
+	output, err := stringifyEstimateReport(&report)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return output
+}
+
+func estimateBias(estimate, actual float64) string {
+	if estimate == 0 {
+		return "nothing finished yet"
+	}
+	ratio := actual / estimate
+	percent := int(math.Round(math.Abs(ratio-1) * 100))
+	switch {
+	case percent == 0:
+		return "1.00x, right on"
+	case ratio > 1:
+		return fmt.Sprintf("%.2fx, items take %d%% longer than estimated", ratio, percent)
+	}
+	return fmt.Sprintf("%.2fx, items take %d%% less time than estimated", ratio, percent)
+}
+
+func stringifyEstimateReport(r *estimateReport) (string, error) {
+	rJson, err := jsonMarshalIndent(r, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return "", err
+	}
+	if EXPORT_TYPE == "yaml" {
+		return printYaml(rJson)
+	}
+	return string(rJson), nil
+}
+
+func addEstimateNote(c *ctxclient.Context, q *ctxclient.Queue) error {
+	estimate := getQueueMeta(q).Estimate
+	if estimate == "" {
+		return nil
+	}
+	notes, err := noteStrings(c.Notes)
+	if err != nil {
+		return err
+	}
+	setNotes(c, append(notes, estimateNote(q.Id, estimate)))
+	return nil
+}
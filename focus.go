@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+func focusCmd(ctxClient *ctxclient.ContextClient, current *ctxclient.Context, args []string) string {
+	if current.ContextId == "" {
+		return "no current context to focus on"
+	}
+	breakLength, args := flagValue(args, "--break", "-b")
+	length := focusLength(CTX_FOCUS_LENGTH)
+	if len(args) > 0 {
+		length = focusLength(args[0])
+	}
+	if breakLength == "" {
+		breakLength = CTX_FOCUS_BREAK
+	}
+	rest := focusLength(breakLength)
+
+	started := time.Now().UTC()
+	if !countdown(fmt.Sprintf("focus on '%s'", current.Name), length) {
+		return fmt.Sprintf("focus stopped after %s, not recorded", formatMinutes(time.Since(started).Minutes()))
+	}
+	err := recordFocus(ctxClient, current.ContextId, length, started)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	notify(fmt.Sprintf("focus on '%s' done", current.Name))
+	fmt.Printf("recorded %s of focus on '%s'\n", formatMinutes(length.Minutes()), current.Name)
+
+	switch strings.ToLower(getLine(fmt.Sprintf("switch context, take a %s break or carry on? [s/b/N]: ", formatMinutes(rest.Minutes())), false)) {
+	case "s", "switch":
+		return switchCtx(ctxClient, current, []string{})
+	case "b", "break":
+		if !countdown("break", rest) {
+			return "break stopped"
+		}
+		notify("break's over")
+		return "break's over"
+	}
+	return ""
+}
+
+func focusLength(length string) time.Duration {
+	d, err := parseLength(length)
+	if err != nil {
+		fmt.Printf("Error: invalid focus length '%s', use something like 25m or 1h\n", length)
+		os.Exit(exitUsage)
+	}
+	return d
+}
+
+func countdown(label string, length time.Duration) bool {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	end := time.Now().Add(length)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		left := time.Until(end).Round(time.Second)
+		if left <= 0 {
+			fmt.Printf("\r%s: done%s\n", label, strings.Repeat(" ", 10))
+			return true
+		}
+		fmt.Printf("\r%s: %s left (ctrl-c to stop) ", label, clock(left))
+		select {
+		case <-interrupt:
+			fmt.Println()
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+func clock(d time.Duration) string {
+	seconds := int(d.Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+func recordFocus(ctxClient *ctxclient.ContextClient, contextId string, length time.Duration, started time.Time) error {
+	// notes may have been added while the timer ran
+	c, err := ctxClient.GetContext(contextId)
+	if err != nil {
+		return err
+	}
+	encoded, err := encodeNote(note{
+		Type:   noteTypeKV,
+		At:     time.Now().UTC().Format(ctxclient.SkDateFormat),
+		Source: noteSourceHook,
+		Values: map[string]string{
+			"focus":   formatMinutes(length.Minutes()),
+			"started": started.Format(ctxclient.SkDateFormat),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	notes, err := noteStrings(c.Notes)
+	if err != nil {
+		return err
+	}
+	setNotes(c, append(notes, encoded))
+	_, err = ctxClient.UpdateContext(c)
+	return err
+}
+
+func notify(message string) {
+	fmt.Print("\a")
+	fields := strings.Fields(CTX_NOTIFY_COMMAND)
+	if len(fields) == 0 {
+		return
+	}
+	err := exec.Command(fields[0], append(fields[1:], message)...).Run()
+	if err != nil {
+		fmt.Printf("couldn't run %s: %v\n", CTX_NOTIFY_COMMAND, err)
+	}
+}
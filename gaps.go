@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type gap struct {
+	Kind    string
+	Context ctxclient.Context
+	// the context after an untracked gap
+	Next    ctxclient.Context
+	Start   time.Time
+	End     time.Time
+	Reasons []string
+}
+
+func gapsCmd(ctxClient *ctxclient.ContextClient, args []string) string {
+	since, args := flagValue(args, "--since")
+	max, args := flagValue(args, "--max")
+	minGap, args := flagValue(args, "--gap")
+	night, _ := flagValue(args, "--night")
+	if since == "" {
+		since = "1w"
+	}
+	maxLength := gapsLength("--max", defaultString(max, "4h"))
+	gapLength := gapsLength("--gap", defaultString(minGap, "30m"))
+	nightStart, nightEnd := nightHours(defaultString(night, "0-6"))
+	start, unit, _, err := parseSince(since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	cs, err := ctxClient.ListContexts(ctxclient.QSParams{
+		"start": start,
+		"end":   "0",
+		"unit":  unit,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	contexts := *cs
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].Created < contexts[j].Created
+	})
+
+	gaps := []gap{}
+	now := time.Now()
+	for i, c := range contexts {
+		created, err := time.Parse(ctxclient.SkDateFormat, c.Created)
+		if err != nil {
+			continue
+		}
+		completed := now
+		if c.Completed != "" {
+			completed, err = time.Parse(ctxclient.SkDateFormat, c.Completed)
+			if err != nil {
+				continue
+			}
+		}
+		reasons := []string{}
+		paused := time.Duration(pausedMinutes(c.Notes, created, completed) * float64(time.Minute))
+		if completed.Sub(created)-paused > maxLength {
+			reasons = append(reasons, fmt.Sprintf("over %s", formatMinutes(maxLength.Minutes())))
+		}
+		for _, s := range activeSpans(c.Notes, created, completed) {
+			if spansNight(s.Start.Local(), s.End.Local(), nightStart, nightEnd) {
+				reasons = append(reasons, "runs through the night")
+				break
+			}
+		}
+		if len(reasons) > 0 {
+			gaps = append(gaps, gap{Kind: "long", Context: c, Start: created, End: completed, Reasons: reasons})
+		}
+		if c.Completed == "" || i+1 >= len(contexts) {
+			continue
+		}
+		next, err := time.Parse(ctxclient.SkDateFormat, contexts[i+1].Created)
+		if err == nil && next.Sub(completed) > gapLength {
+			gaps = append(gaps, gap{Kind: "gap", Context: c, Next: contexts[i+1], Start: completed, End: next})
+		}
+	}
+	if len(gaps) == 0 {
+		return fmt.Sprintf("no long contexts or gaps in the last %s", since)
+	}
+	for _, g := range gaps {
+		fmt.Println(describeGap(g))
+	}
+	if !confirm("\nfix any of these? [y/N]: ", "n") {
+		return ""
+	}
+	fixed := 0
+	for _, g := range gaps {
+		fmt.Printf("\n%s\n", describeGap(g))
+		done, stop := fixGap(ctxClient, g)
+		if done {
+			fixed++
+		}
+		if stop {
+			break
+		}
+	}
+	return fmt.Sprintf("\nfixed %d of %d", fixed, len(gaps))
+}
+
+func describeGap(g gap) string {
+	span := fmt.Sprintf("%s to %s", g.Start.Local().Format("Mon 01-02 15:04"), g.End.Local().Format("Mon 01-02 15:04"))
+	if g.Kind == "gap" {
+		return fmt.Sprintf("gap: %s untracked between '%s' and '%s', %s", formatMinutes(g.End.Sub(g.Start).Minutes()), g.Context.Name, g.Next.Name, span)
+	}
+	open := ""
+	if g.Context.Completed == "" {
+		open = ", still open"
+	}
+	return fmt.Sprintf("long: '%s' (%s) ran %s, %s%s (%s)", g.Context.Name, g.Context.ContextId, formatMinutes(g.End.Sub(g.Start).Minutes()), span, open, strings.Join(g.Reasons, ", "))
+}
+
+func fixGap(ctxClient *ctxclient.ContextClient, g gap) (bool, bool) {
+	if g.Kind == "gap" {
+		switch strings.ToLower(getLine("[b]reak context to fill it, [s]kip or [q]uit: ", false)) {
+		case "b", "break":
+			return insertContext(ctxClient, ctxclient.Context{Name: "break", LastContext: g.Context.ContextId}, g.Start, g.End), false
+		case "q", "quit":
+			return false, true
+		}
+		return false, false
+	}
+	if g.Context.Completed == "" {
+		fmt.Println("close or switch from it first to fix it")
+		return false, false
+	}
+	switch strings.ToLower(getLine("[t]rim the end, s[p]lit around a break, [s]kip or [q]uit: ", false)) {
+	case "t", "trim":
+		end := askTime("end it at (15:04 or 2006-01-02 15:04): ", g.Start, g.Start, g.End)
+		return trimContext(ctxClient, g.Context, end), false
+	case "p", "split":
+		from := askTime("break started at: ", g.Start, g.Start, g.End)
+		to := askTime("break ended at: ", from, from, g.End)
+		if !trimContext(ctxClient, g.Context, from) {
+			return false, false
+		}
+		// the time after the break goes on a resumed copy of the context
+		resumed := ctxclient.Context{
+			Name:        g.Context.Name,
+			ParentId:    g.Context.ParentId,
+			LastContext: g.Context.ContextId,
+			Document:    g.Context.Document,
+		}
+		return insertContext(ctxClient, resumed, to, g.End), false
+	case "q", "quit":
+		return false, true
+	}
+	return false, false
+}
+
+func askTime(prompt string, base, min, max time.Time) time.Time {
+	for {
+		t, err := parseClock(getLine(prompt, true), base.Local())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		if t.Before(min) || t.After(max) {
+			fmt.Printf("Error: pick a time from %s to %s\n", min.Local().Format("01-02 15:04"), max.Local().Format("01-02 15:04"))
+			continue
+		}
+		return t
+	}
+}
+
+func parseClock(s string, base time.Time) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, base.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04", "3:04pm", "3pm"} {
+		clock, err := time.ParseInLocation(layout, strings.ToLower(strings.ReplaceAll(s, " ", "")), base.Location())
+		if err != nil {
+			continue
+		}
+		t := time.Date(base.Year(), base.Month(), base.Day(), clock.Hour(), clock.Minute(), 0, 0, base.Location())
+		// a time of day on its own is the next one after base
+		if t.Before(base) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("can't read time '%s'", s)
+}
+
+func trimContext(ctxClient *ctxclient.ContextClient, c ctxclient.Context, end time.Time) bool {
+	c.Completed = end.UTC().Format(ctxclient.SkDateFormat)
+	_, err := ctxClient.UpdateContext(&c)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	fmt.Printf("'%s' now ends at %s\n", c.Name, end.Local().Format("01-02 15:04"))
+	return true
+}
+
+func insertContext(ctxClient *ctxclient.ContextClient, c ctxclient.Context, start, end time.Time) bool {
+	c.ContextId = start.UTC().Format(ctxclient.SkDateFormat)
+	c.Created = c.ContextId
+	c.Completed = end.UTC().Format(ctxclient.SkDateFormat)
+	existing, err := ctxClient.GetContext(c.ContextId)
+	if err == nil && existing.ContextId != "" {
+		fmt.Printf("Error: there's already a context starting at %s\n", c.ContextId)
+		return false
+	}
+	// contexts are keyed by when they were created and the api upserts by
+	// contextId, keeping created and completed as sent. make sure it did or
+	// the context isn't where the gap was
+	newContextId, err := ctxClient.UpdateContext(&c)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	saved, err := ctxClient.GetContext(newContextId)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	if newContextId != c.ContextId || saved.Created != c.Created || saved.Completed != c.Completed {
+		fmt.Printf("Error: the api saved '%s' as %s from %s to %s instead of %s to %s, check it with ctx get %s\n", c.Name, newContextId, saved.Created, saved.Completed, c.Created, c.Completed, newContextId)
+		return false
+	}
+	fmt.Printf("added '%s' from %s to %s\n", c.Name, start.Local().Format("01-02 15:04"), end.Local().Format("01-02 15:04"))
+	return true
+}
+
+func gapsLength(flag, length string) time.Duration {
+	d, err := parseLength(length)
+	if err != nil {
+		fmt.Printf("Error: invalid %s '%s', use something like 4h, 90m or 30\n", flag, length)
+		os.Exit(exitUsage)
+	}
+	return d
+}
+
+func nightHours(hours string) (int, int) {
+	parts := strings.SplitN(hours, "-", 2)
+	if len(parts) == 2 {
+		start, err1 := strconv.Atoi(parts[0])
+		end, err2 := strconv.Atoi(parts[1])
+		if err1 == nil && err2 == nil && start >= 0 && start < 24 && end >= 0 && end <= 24 {
+			return start, end
+		}
+	}
+	fmt.Printf("Error: invalid night hours '%s', use something like 0-6 or 22-6\n", hours)
+	os.Exit(exitUsage)
+	return 0, 0
+}
+
+func spansNight(start, end time.Time, nightStart, nightEnd int) bool {
+	// working a little late doesn't count, the whole night has to be covered
+	for day := startOfDay(start).AddDate(0, 0, -1); !day.After(end); day = day.AddDate(0, 0, 1) {
+		from := day.Add(time.Duration(nightStart) * time.Hour)
+		to := day.Add(time.Duration(nightEnd) * time.Hour)
+		if nightEnd <= nightStart {
+			to = to.AddDate(0, 0, 1)
+		}
+		if !from.Before(start) && !to.After(end) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
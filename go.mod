@@ -7,6 +7,7 @@ toolchain go1.23.2
 require (
 	github.com/charlesrobsampson/ctxclient v0.0.1
 	github.com/go-git/go-git/v5 v5.14.0
+	github.com/yuin/goldmark v1.8.6
 	gopkg.in/yaml.v3 v3.0.1
 )
 
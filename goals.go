@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+const (
+	goalPeriodDay  = "day"
+	goalPeriodWeek = "week"
+)
+
+type goal struct {
+	Name    string  `json:"name"`
+	Minutes float64 `json:"minutes"`
+	Period  string  `json:"period"`
+}
+
+type goalProgress struct {
+	Goal    string  `json:"goal"`
+	Period  string  `json:"period"`
+	Target  string  `json:"target"`
+	Spent   string  `json:"spent"`
+	Percent float64 `json:"percent"`
+	Left    string  `json:"left,omitempty"`
+}
+
+func goalsPath() string {
+	return filepath.Join(CTX_STATE_DIR, "goals.json")
+}
+
+func readGoals() ([]goal, error) {
+	goals := []goal{}
+	data, err := os.ReadFile(goalsPath())
+	if os.IsNotExist(err) {
+		return goals, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &goals)
+	return goals, err
+}
+
+func writeGoals(goals []goal) error {
+	err := os.MkdirAll(CTX_STATE_DIR, 0755)
+	if err != nil {
+		return err
+	}
+	data, err := jsonMarshalIndent(goals, false)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(goalsPath(), data, 0644)
+}
+
+func goalCmd(ctxClient *ctxclient.ContextClient, args []string) string {
+	if len(args) == 0 {
+		return goalsReport(ctxClient)
+	}
+	goals, err := readGoals()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	switch args[0] {
+	case "set":
+		if len(args) < 3 {
+			fmt.Printf("Error: usage: ctx goal set <name> <time>/<day|week>\n")
+			os.Exit(exitUsage)
+		}
+		name := strings.Join(args[1:len(args)-1], " ")
+		minutes, period, err := parseGoal(args[len(args)-1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		g := goal{Name: name, Minutes: minutes, Period: period}
+		replaced := false
+		for i := range goals {
+			if strings.EqualFold(goals[i].Name, name) && goals[i].Period == period {
+				goals[i] = g
+				replaced = true
+			}
+		}
+		if !replaced {
+			goals = append(goals, g)
+		}
+		err = writeGoals(goals)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		return fmt.Sprintf("goal set: %s of '%s' a %s", formatMinutes(minutes), name, period)
+	case "rm", "remove":
+		if len(args) < 2 {
+			fmt.Printf("Error: missing goal name\n")
+			os.Exit(exitUsage)
+		}
+		name := strings.Join(args[1:], " ")
+		kept := []goal{}
+		for _, g := range goals {
+			if !strings.EqualFold(g.Name, name) {
+				kept = append(kept, g)
+			}
+		}
+		if len(kept) == len(goals) {
+			return fmt.Sprintf("no goal for '%s'", name)
+		}
+		err = writeGoals(kept)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		return fmt.Sprintf("removed goal for '%s'", name)
+	}
+	usageError(fmt.Sprintf("unknown goal command '%s'", args[0]), "", "ctx help goal")
+	return ""
+}
+
+func parseGoal(spec string) (float64, string, error) {
+	amount, period, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, "", fmt.Errorf("invalid goal '%s', use something like 4h/day or 20h/week", spec)
+	}
+	period = strings.TrimSuffix(strings.ToLower(period), "s")
+	switch period {
+	case "d", "day", "daily":
+		period = goalPeriodDay
+	case "w", "wk", "week", "weekly":
+		period = goalPeriodWeek
+	default:
+		return 0, "", fmt.Errorf("invalid goal period '%s', use day or week", period)
+	}
+	d, err := time.ParseDuration(amount)
+	if err != nil || d <= 0 {
+		return 0, "", fmt.Errorf("invalid goal time '%s', use something like 4h or 90m", amount)
+	}
+	return d.Minutes(), period, nil
+}
+
+func goalsReport(ctxClient *ctxclient.ContextClient) string {
+	goals, err := readGoals()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(goals) == 0 {
+		return "no goals set, add one with ctx goal set <name> <time>/<day|week>"
+	}
+	progress, err := goalsProgress(ctxClient, goals)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	output, err := stringifyGoalProgress(&progress)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return output
+}
+
+func checkGoals(ctxClient *ctxclient.ContextClient) string {
+	goals, err := readGoals()
+	if err != nil || len(goals) == 0 {
+		return ""
+	}
+	progress, err := goalsProgress(ctxClient, goals)
+	if err != nil {
+		// the reminder shouldn't get in the way of the command
+		return ""
+	}
+	output := "\n"
+	for _, p := range progress {
+		when := "today"
+		if p.Period == goalPeriodWeek {
+			when = "this week"
+		}
+		output += fmt.Sprintf("goal '%s': %s of %s %s (%.0f%%)\n", p.Goal, p.Spent, p.Target, when, p.Percent)
+	}
+	return output
+}
+
+func goalsProgress(ctxClient *ctxclient.ContextClient, goals []goal) ([]goalProgress, error) {
+	now := time.Now()
+	earliest := now
+	for _, g := range goals {
+		if start := goalPeriodStart(g.Period, now); start.Before(earliest) {
+			earliest = start
+		}
+	}
+	// the api lists contexts by when they were created, so go back a day to
+	// catch ones that were started before the period and ran into it
+	hours := int(now.Sub(earliest).Hours()) + 25
+	cs, err := ctxClient.ListContexts(ctxclient.QSParams{
+		"start": fmt.Sprint(hours),
+		"end":   "0",
+		"unit":  "h",
+	})
+	if err != nil {
+		return nil, err
+	}
+	lineages := contextLineage(ctxClient, *cs)
+	progress := []goalProgress{}
+	for _, g := range goals {
+		start := goalPeriodStart(g.Period, now)
+		spent := 0.0
+		for _, c := range *cs {
+			if goalMatches(g.Name, lineages[c.ContextId]) {
+				spent += minutesBetween(c, start, now)
+			}
+		}
+		p := goalProgress{
+			Goal:   g.Name,
+			Period: g.Period,
+			Target: formatMinutes(g.Minutes),
+			Spent:  formatMinutes(spent),
+		}
+		if g.Minutes > 0 {
+			p.Percent = float64(int(spent/g.Minutes*100 + 0.5))
+		}
+		if spent < g.Minutes {
+			p.Left = formatMinutes(g.Minutes - spent)
+		}
+		progress = append(progress, p)
+	}
+	sort.SliceStable(progress, func(i, j int) bool {
+		return progress[i].Period < progress[j].Period
+	})
+	return progress, nil
+}
+
+func goalPeriodStart(period string, now time.Time) time.Time {
+	start := startOfDay(now)
+	if period == goalPeriodWeek {
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	}
+	return start
+}
+
+func goalMatches(name string, lineage []ctxclient.Context) bool {
+	if len(lineage) == 0 {
+		return false
+	}
+	if strings.EqualFold(lineage[len(lineage)-1].Name, name) {
+		return true
+	}
+	for _, c := range lineage {
+		if hasTag(c.Notes, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func minutesBetween(c ctxclient.Context, from, to time.Time) float64 {
+	created, completed, ok := contextSpan(c, to)
+	if !ok {
+		return 0
+	}
+	total := 0.0
+	for _, s := range activeSpans(c.Notes, created, completed) {
+		if s.Start.Before(from) {
+			s.Start = from
+		}
+		if s.End.After(to) {
+			s.End = to
+		}
+		if s.End.After(s.Start) {
+			total += s.End.Sub(s.Start).Minutes()
+		}
+	}
+	return total
+}
+
+func hasTag(raw json.RawMessage, tag string) bool {
+	notes, err := readNotes(raw)
+	if err != nil {
+		return false
+	}
+	// multi word tags are written with dashes, like deep-work
+	want := strings.ReplaceAll(strings.ToLower(tag), " ", "-")
+	for _, n := range notes {
+		if n.Type != noteTypeKV {
+			continue
+		}
+		for _, key := range []string{"tag", "tags"} {
+			for _, t := range strings.FieldsFunc(n.Values[key], func(r rune) bool { return r == ',' || r == ' ' }) {
+				if strings.ToLower(t) == want {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func stringifyGoalProgress(p *[]goalProgress) (string, error) {
+	pJson, err := jsonMarshalIndent(p, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return "", err
+	}
+	if EXPORT_TYPE == "yaml" {
+		return printYaml(pJson)
+	}
+	return string(pJson), nil
+}
@@ -7,14 +7,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/go-git/go-git/v5"
 	"gopkg.in/yaml.v3"
 
 	"github.com/charlesrobsampson/ctxclient"
@@ -23,14 +21,22 @@ import (
 const version = "v1.3.2"
 
 var (
-	HOST                = os.Getenv("CTX_HOST")
-	USER                = os.Getenv("CTX_USER")
-	CTX_DOCS_PATH       = os.Getenv("CTX_DOCS_PATH")
-	CTX_GITHUB_DOCS_URL = os.Getenv("CTX_GITHUB_DOCS_URL")
-	EXPORT_TYPE         = defaultEnv("CTX_EXPORT_TYPE", "json")
-	CTX_REPORT_UPDATES  = defaultEnv("CTX_REPORT_UPDATES", "true")
-	CTX_DEFAULT_EDITOR  = defaultEnv("CTX_DEFAULT_EDITOR", "code")
-	timeUnits           = map[string]string{
+	HOST                 = os.Getenv("CTX_HOST")
+	USER                 = os.Getenv("CTX_USER")
+	CTX_DOCS_PATH        = os.Getenv("CTX_DOCS_PATH")
+	CTX_GITHUB_DOCS_URL  = os.Getenv("CTX_GITHUB_DOCS_URL")
+	EXPORT_TYPE          = defaultEnv("CTX_EXPORT_TYPE", "json")
+	CTX_REPORT_UPDATES   = defaultEnv("CTX_REPORT_UPDATES", "true")
+	CTX_REPORT_OVERDUE   = defaultEnv("CTX_REPORT_OVERDUE", "true")
+	CTX_DEFAULT_EDITOR   = os.Getenv("CTX_DEFAULT_EDITOR")
+	CTX_DOCS_SYNC_EVERY  = defaultEnv("CTX_DOCS_SYNC_EVERY", "10m")
+	CTX_DOCS_BRANCHES    = defaultEnv("CTX_DOCS_BRANCHES", "false")
+	CTX_DOCS_MAIN_BRANCH = defaultEnv("CTX_DOCS_MAIN_BRANCH", "main")
+	CTX_STATE_DIR        = defaultEnv("CTX_STATE_DIR", defaultStateDir())
+	CTX_FOCUS_LENGTH     = defaultEnv("CTX_FOCUS_LENGTH", "25m")
+	CTX_FOCUS_BREAK      = defaultEnv("CTX_FOCUS_BREAK", "5m")
+	CTX_NOTIFY_COMMAND   = os.Getenv("CTX_NOTIFY_COMMAND")
+	timeUnits            = map[string]string{
 		"s": "seconds",
 		"m": "minutes",
 		"h": "hours",
@@ -42,258 +48,54 @@ var (
 )
 
 func main() {
+	args := os.Args[1:]
+	commands := newRegistry()
+	var cmd *command
+	path := ""
+	if len(args) > 0 {
+		if args[0] == "--help" || args[0] == "-h" {
+			args[0] = "help"
+		}
+		cmd, args, path = resolveCommand(commands, args)
+		// help doesn't need the api, so it works before ctx is set up
+		if cmd.Name == "help" {
+			fmt.Println(helpCmd(commands, args))
+			return
+		}
+		if wantsHelp(args) {
+			fmt.Println(helpCommand(cmd, path))
+			return
+		}
+		if path == "doc sync" && containsString(args, "--background") {
+			println(backgroundDocSync())
+			return
+		}
+		args = checkFlags(cmd, path, args)
+	}
 	if HOST == "" {
 		fmt.Println("CTX_HOST environment variable not set")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if USER == "" {
 		fmt.Println("CTX_USER environment variable not set")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
-	allArgs := os.Args
-	args := allArgs[1:]
 	output := ""
 	ctxClient := ctxclient.NewContextClient(HOST, USER)
 	qClient := ctxclient.NewQueueClient(HOST, USER)
 	current, err := ctxClient.GetCurrentContext()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
-	if len(args) == 0 {
-		output = currentCtx(ctxClient, current)
+	if cmd == nil {
+		output = currentCtx(ctxClient, current) + checkOverdue(qClient) + checkGoals(ctxClient)
 	} else {
-		cmd := args[0]
-		args = args[1:]
-		switch cmd {
-		case "v", "version":
-			output = checkVersions(ctxClient, true)
-		case "g", "get":
-			outputChan := make(chan string)
-			versionCheckChan := make(chan string)
-			go func(outputChan chan string) {
-				output := getCtx(ctxClient, args)
-				outputChan <- output
-			}(outputChan)
-			go func(versionCheckChan chan string) {
-				versionCheck := checkVersions(ctxClient, false)
-				versionCheckChan <- versionCheck
-			}(versionCheckChan)
-			output = <-outputChan
-			versionCheck := <-versionCheckChan
-			if len(versionCheck) > 0 {
-				output += versionCheck
-			}
-		case "l", "last":
-			output = lastCtx(ctxClient)
-		case "ls", "list":
-			output = listCtx(ctxClient)
-		case "sum", "summary":
-			output = summaryCtx(ctxClient)
-		case "s", "switch":
-			output = switchCtx(ctxClient, current, args)
-		case "-", "sub":
-			output = switchCtx(ctxClient, current, []string{"sub"})
-		case "=", "same":
-			output = switchCtx(ctxClient, current, []string{"same"})
-		case "n", "note":
-			outputChan := make(chan string)
-			versionCheckChan := make(chan string)
-			go func(outputChan chan string) {
-				output := addNoteCtx(ctxClient, current)
-				outputChan <- output
-			}(outputChan)
-			go func(versionCheckChan chan string) {
-				versionCheck := checkVersions(ctxClient, false)
-				versionCheckChan <- versionCheck
-			}(versionCheckChan)
-			output = <-outputChan
-			versionCheck := <-versionCheckChan
-			if len(versionCheck) > 0 {
-				output += versionCheck
-			}
-		case "c", "close":
-			outputChan := make(chan string)
-			versionCheckChan := make(chan string)
-			go func(outputChan chan string) {
-				output := closeCtx(ctxClient, args)
-				outputChan <- output
-			}(outputChan)
-			go func(versionCheckChan chan string) {
-				versionCheck := checkVersions(ctxClient, false)
-				versionCheckChan <- versionCheck
-			}(versionCheckChan)
-			output = <-outputChan
-			versionCheck := <-versionCheckChan
-			if len(versionCheck) > 0 {
-				output += versionCheck
-			}
-		case "r", "resume":
-			output = resumeCtx(ctxClient, args)
-		case "tm", "timeMachine":
-			if current.LastContext == "" {
-				output = fmt.Sprintf("current context '%s' has no last context\n", current.Name)
-			} else {
-				timeMachineCtx(ctxClient, current.LastContext)
-			}
-		case "p", "parents":
-			if len(args) > 0 {
-				parentsCtx(ctxClient, args[0])
-			} else {
-				if current.ParentId == "" {
-					output = fmt.Sprintf("current context '%s' has no parent\n", current.Name)
-				} else {
-					parentsCtx(ctxClient, current.ParentId)
-				}
-			}
-		case "q":
-			if len(args) == 0 {
-				output = listQueue(qClient)
-			} else {
-				cmd := args[0]
-				switch cmd {
-				case "g", "get":
-					outputChan := make(chan string)
-					versionCheckChan := make(chan string)
-					go func(outputChan chan string) {
-						output := getQueue(qClient, args)
-						outputChan <- output
-					}(outputChan)
-					go func(versionCheckChan chan string) {
-						versionCheck := checkVersions(ctxClient, false)
-						versionCheckChan <- versionCheck
-					}(versionCheckChan)
-					output = <-outputChan
-					versionCheck := <-versionCheckChan
-					if len(versionCheck) > 0 {
-						output += versionCheck
-					}
-				case "a", "add":
-					output = addQueue(qClient)
-				case "d", "do":
-					output = doQueue(qClient, ctxClient, args)
-				case "n", "note":
-					output = addNoteQueue(qClient, args)
-				case "c", "close":
-					output = closeQueue(qClient, args)
-				default:
-					output = "unkown q command"
-				}
-			}
-		case "d", "doc":
-			if CTX_DOCS_PATH == "" {
-				output = "CTX_DOCS_PATH environment variable not set"
-			} else {
-				wg := sync.WaitGroup{}
-				syncNotes(&wg)
-				cmd := "e"
-				// cmd := "s"
-				if len(args) > 0 {
-					cmd = args[0]
-					args = args[1:]
-				}
-				if cmd == "s" || cmd == "sync" {
-					wg.Wait()
-					output = "notes synced"
-				} else {
-					dateString := strings.Split(current.ContextId, "T")[0]
-					dateSlice := strings.Split(dateString, "-")
-					year := dateSlice[0]
-					month := dateSlice[1]
-					day := dateSlice[2]
-					dirPath := fmt.Sprintf("ctx/%s/%s/%s", year, month, day)
-					fileName := fmt.Sprintf("%s.md", strings.ReplaceAll(current.Name, " ", "_"))
-					absolutePath := fmt.Sprintf("%s/%s/%s", CTX_DOCS_PATH, dirPath, fileName)
-					wg.Wait()
-					switch cmd {
-					case "e", "edit":
-						if current.Document.RealtivePath == "" {
-							// create new doc
-							fmt.Println("creating new doc")
-							// first check if file exists
-							_, err := os.Stat(absolutePath)
-							if err != nil {
-								mkdirCmd := exec.Command("mkdir", "-p", fmt.Sprintf("%s/%s", CTX_DOCS_PATH, dirPath))
-								err := mkdirCmd.Run()
-								if err != nil {
-									fmt.Printf("Error: %v\n", err)
-									os.Exit(1)
-								}
-								createCmd := exec.Command("touch", absolutePath)
-								err = createCmd.Run()
-								if err != nil {
-									fmt.Printf("Error: %v\n", err)
-									os.Exit(1)
-								}
-								if current.ParentId != "" {
-									parentDoc, err := findParentDoc(ctxClient, current.ParentId)
-									if err != nil {
-										fmt.Printf("Error: %v\n", err)
-										os.Exit(1)
-									}
-									if parentDoc.RealtivePath != "" {
-										parentString := fmt.Sprintf("[parent doc](%s)\n", parentDoc.RealtivePath)
-										os.WriteFile(absolutePath, []byte(parentString), 0644)
-									}
-								}
-							}
-							current.Document.RealtivePath = fmt.Sprintf("%s/%s", dirPath, fileName)
-							if CTX_GITHUB_DOCS_URL != "" {
-								current.Document.Github = fmt.Sprintf("%s/%s", CTX_GITHUB_DOCS_URL, current.Document.RealtivePath)
-							}
-							_, err = ctxClient.UpdateContext(current)
-							if err != nil {
-								fmt.Printf("Error adding doc to context: %v\n", err)
-								os.Exit(1)
-							}
-						}
-						// edit existing doc
-						output = fmt.Sprintf("opening doc:\n%s", absolutePath)
-						openCmd := exec.Command(CTX_DEFAULT_EDITOR, fmt.Sprintf("%s/%s", CTX_DOCS_PATH, current.Document.RealtivePath))
-						err = openCmd.Run()
-						if err != nil {
-							fmt.Printf("Error: %v\n", err)
-							os.Exit(1)
-						}
-					case "l", "link":
-						output = "not implemented"
-						// link doc to context
-						// if a doc is already linked, prompt to start new ctx
-					case "o", "open":
-						realtivePath := current.Document.RealtivePath
-						// open doc in editor
-						// if none, open closest parent
-						// if no parent, return saying none found and prompt to create new
-						if realtivePath == "" {
-							parentDoc, err := findParentDoc(ctxClient, current.ContextId)
-							if err != nil {
-								fmt.Printf("Error: %v\n", err)
-								os.Exit(1)
-							}
-							if parentDoc.RealtivePath != "" {
-								realtivePath = parentDoc.RealtivePath
-							}
-						}
-						if realtivePath == "" {
-							output = "no related docs found. create one with\nctx doc edit"
-						} else {
-							output = fmt.Sprintf("opening doc: %s", realtivePath)
-							openCmd := exec.Command(CTX_DEFAULT_EDITOR, fmt.Sprintf("%s/%s", CTX_DOCS_PATH, realtivePath))
-							err = openCmd.Run()
-							if err != nil {
-								fmt.Printf("Error: %v\n", err)
-								os.Exit(1)
-							}
-						}
-					default:
-						output = "unkown doc command"
-					}
-				}
-			}
-
-		default:
-			output = "Unknown command"
-		}
+		output = runCommand(cmd, &commandEnv{
+			ctxClient: ctxClient,
+			qClient:   qClient,
+			current:   current,
+		}, args)
 	}
 	println(output)
 }
@@ -302,7 +104,7 @@ func currentCtx(ctxClient *ctxclient.ContextClient, c *ctxclient.Context) string
 	output, err := stringifyContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if c.UserId == "" {
 		output = "no current context"
@@ -311,7 +113,7 @@ func currentCtx(ctxClient *ctxclient.ContextClient, c *ctxclient.Context) string
 			parentContext, err := ctxClient.GetContext(c.ParentId)
 			if err != nil {
 				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitError)
 			}
 			fmt.Printf("parent: %s\n", parentContext.Name)
 			fmt.Printf("parentId: %s\n", parentContext.ContextId)
@@ -320,10 +122,16 @@ func currentCtx(ctxClient *ctxclient.ContextClient, c *ctxclient.Context) string
 		startedTime, err := time.Parse(ctxclient.SkDateFormat, c.Created)
 		if err != nil {
 			fmt.Printf("Error parsing start time: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
-		diff := currentTime.Sub(startedTime).Minutes()
+		diff := currentTime.Sub(startedTime).Minutes() - pausedMinutes(c.Notes, startedTime, currentTime)
 		fmt.Printf("minutes on current context: %d\n", int(diff+0.5))
+		if paused, ok := pausedSince(c); ok {
+			fmt.Printf("paused since: %s\n", paused.Local().Format("15:04"))
+		}
+		if progress := checklistProgress(c.Notes); progress != "" {
+			fmt.Printf("checklist: %s\n", progress)
+		}
 	}
 	return output
 }
@@ -332,18 +140,18 @@ func getCtx(ctxClient *ctxclient.ContextClient, args []string) string {
 	output := ""
 	if len(args) == 0 {
 		fmt.Printf("Error: missing contextId\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 	contextId := args[0]
 	c, err := ctxClient.GetContext(contextId)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output, err = stringifyContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if output == "{}" {
 		output = fmt.Sprintf("context '%s' not found", contextId)
@@ -356,12 +164,12 @@ func lastCtx(ctxClient *ctxclient.ContextClient) string {
 	c, err := ctxClient.GetContext("last")
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output, err = stringifyContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if output == "{}" {
 		output = "no last context"
@@ -379,12 +187,12 @@ func listCtx(ctxClient *ctxclient.ContextClient) string {
 	})
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output, err = stringifyList(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if output == "{}" {
 		output = "no last context"
@@ -395,20 +203,27 @@ func listCtx(ctxClient *ctxclient.ContextClient) string {
 func summaryCtx(ctxClient *ctxclient.ContextClient) string {
 	output := ""
 	start, end, unit := getQueryWindow()
-	ctxs, err := ctxClient.ListFormattedContexts(ctxclient.QSParams{
+	window := ctxclient.QSParams{
 		"start": start,
 		"end":   end,
 		"unit":  unit,
-	})
+	}
+	ctxs, err := ctxClient.ListFormattedContexts(window)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	contexts, err := ctxClient.ListContexts(window)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
+	excludePauses(ctxs, *contexts)
 	output, err = stringifyFormatted(&ctxs)
 
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	fmt.Println()
 	return output
@@ -418,6 +233,7 @@ func switchCtx(ctxClient *ctxclient.ContextClient, currentContext *ctxclient.Con
 	output := ""
 	isSubContext := false
 	sameParent := false
+	from, args := noteSourceFlag(args, false)
 	if len(args) > 0 {
 		isSubContext = args[0] == "sub" || args[0] == "-"
 		sameParent = args[0] == "same" || args[0] == "="
@@ -427,7 +243,7 @@ func switchCtx(ctxClient *ctxclient.ContextClient, currentContext *ctxclient.Con
 			parentContext, err := ctxClient.GetContext(currentContext.ParentId)
 			if err != nil {
 				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitError)
 			}
 			fmt.Printf("parent: %s\n", parentContext.Name)
 			fmt.Printf("parentId: %s\n", parentContext.ContextId)
@@ -448,20 +264,24 @@ func switchCtx(ctxClient *ctxclient.ContextClient, currentContext *ctxclient.Con
 			c.ParentId = parentId
 		}
 	}
-	addNotes(&c, "Enter notes for this context (endline with \\ for multiline): ")
+	addNotes(&c, "Enter notes for this context (endline with \\ for multiline): ", from, noteSourceManual)
 	output, err := stringifyContext(&c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	fmt.Printf("new context:\n%s\n", output)
 	makeSwitch := confirm("make switch? [Y/n]: ", "y")
 	if makeSwitch {
+		if currentContext.ContextId != "" {
+			endPause(ctxClient, currentContext.ContextId)
+		}
 		newContextId, err := ctxClient.UpdateContext(&c)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
+		switchDocsBranch(ctxClient, &c)
 		output = fmt.Sprintf("\nupdated context: %s\nwith contextId: %s\n", c.Name, newContextId)
 	} else {
 		output = "cancelled"
@@ -469,19 +289,24 @@ func switchCtx(ctxClient *ctxclient.ContextClient, currentContext *ctxclient.Con
 	return output
 }
 
-func addNoteCtx(ctxClient *ctxclient.ContextClient, c *ctxclient.Context) string {
+func addNoteCtx(ctxClient *ctxclient.ContextClient, c *ctxclient.Context, args []string) string {
 	output := ""
+	notes, from := noteArgs(args)
 	c.ContextId = ""
-	addNotes(c, "add note (endline with \\ for multiline): ")
+	if len(notes) > 0 {
+		setNotes(c, stampNotes(notes, noteSourceManual, ""))
+	} else {
+		addNotes(c, "add note (endline with \\ for multiline): ", from, noteSourceManual)
+	}
 	_, err := stringifyContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	newContextId, err := ctxClient.UpdateContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output = fmt.Sprintf("added note to '%s'\nwith contextId: %s\n", c.Name, newContextId)
 	return output
@@ -493,47 +318,74 @@ func closeCtx(ctxClient *ctxclient.ContextClient, args []string) string {
 		contextId = args[0]
 	}
 	contextId = getLastHash(contextId)
+	closing := &ctxclient.Context{}
+	if docsBranchesEnabled() {
+		c, err := ctxClient.GetContext(contextId)
+		if err == nil {
+			closing = c
+		}
+	}
+	endPause(ctxClient, contextId)
 	response, err := ctxClient.CloseContext(contextId)
 	if err != nil && response != "no current context" && response != fmt.Sprintf("context 'context#%s' not found", contextId) {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+	if err == nil {
+		mergeDocsBranch(ctxClient, closing)
 	}
 	return response
 }
 
 func resumeCtx(ctxClient *ctxclient.ContextClient, args []string) string {
 	output := ""
+	from, args := noteSourceFlag(args, false)
 	if len(args) == 0 {
 		fmt.Printf("Error: missing contextId\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 	contextId := args[0]
 	c, err := ctxClient.GetContext(contextId)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	c.ContextId = ""
 	output, err = stringifyContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	fmt.Printf("resuming context:\n%s\n", output)
-	addNotes(c, "Add notes to this context (endline with \\ for multiline): ")
+	carried, err := openChecklists(c.Notes)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	addNotes(c, "Add notes to this context (endline with \\ for multiline): ", from, noteSourceResume)
+	if len(carried) > 0 {
+		newNotes, err := noteStrings(c.Notes)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		setNotes(c, append(carried, newNotes...))
+	}
 	output, err = stringifyContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	fmt.Printf("new context:\n%s\n", output)
 	makeSwitch := confirm("make switch? [Y/n]: ", "y")
 	if makeSwitch {
+		endPause(ctxClient, "current")
 		newContextId, err := ctxClient.UpdateContext(c)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
+		switchDocsBranch(ctxClient, c)
 		output = fmt.Sprintf("\nupdated context: %s\nwith contextId: %s\n", c.Name, newContextId)
 	} else {
 		output = "cancelled"
@@ -545,12 +397,12 @@ func timeMachineCtx(ctxClient *ctxclient.ContextClient, ctxID string) {
 	c, err := ctxClient.GetContext(ctxID)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output, err := stringifyContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	fmt.Println(output)
 	if c.LastContext != "" {
@@ -568,12 +420,12 @@ func parentsCtx(ctxClient *ctxclient.ContextClient, ctxID string) {
 	c, err := ctxClient.GetContext(ctxID)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output, err := stringifyContext(c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	fmt.Println(output)
 	if c.ParentId != "" {
@@ -587,39 +439,57 @@ func parentsCtx(ctxClient *ctxclient.ContextClient, ctxID string) {
 	}
 }
 
-func listQueue(qClient *ctxclient.QueueClient) string {
+func listQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, args []string) string {
 	output := ""
+	contextId, args := flagValue(args, "--context", "-c")
+	all, _ := hasFlag(args, "--all", "-a")
 	fmt.Println("queue:")
 	q, err := qClient.ListQueue()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+	if contextId != "" {
+		under := underContext(qClient, *q, contextId)
+		q = &under
+	}
+	blocked, snoozed := []ctxclient.Queue{}, []ctxclient.Queue{}
+	if !all {
+		awake, sleeping := awakeQueue(*q)
+		ready, hidden := unblockedQueue(qClient, ctxClient, awake)
+		q, blocked, snoozed = &ready, hidden, sleeping
 	}
+	sortQueue(*q)
 	output, err = stringifyQueueList(q)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+	if len(blocked) > 0 {
+		output += fmt.Sprintf("\n%d blocked items hidden, see ctx q --all or ctx q graph\n", len(blocked))
+	}
+	if len(snoozed) > 0 {
+		output += fmt.Sprintf("\n%d snoozed items hidden, see ctx q --all\n", len(snoozed))
 	}
 	return output
 }
 
 func getQueue(qClient *ctxclient.QueueClient, args []string) string {
 	output := ""
-	args = args[1:]
 	if len(args) == 0 {
 		fmt.Printf("Error: missing queueId\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 	qId := args[0]
 	q, err := qClient.GetQueue(qId)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output, err = stringifyQueue(q)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if output == "{}" {
 		output = fmt.Sprintf("queue '%s' not found", qId)
@@ -627,19 +497,52 @@ func getQueue(qClient *ctxclient.QueueClient, args []string) string {
 	return output
 }
 
-func addQueue(qClient *ctxclient.QueueClient) string {
+func addQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, args []string) string {
 	output := ""
+	priority, args := flagValue(args, "--priority", "-p")
+	due, args := flagValue(args, "--due")
+	every, args := flagValue(args, "--every")
+	after, args := flagValue(args, "--after")
+	estimate, args := flagValue(args, "--estimate")
+	under, args := flagValue(args, "--under")
+	parent, args := flagValue(args, "--parent")
+	from, _ := noteSourceFlag(args, false)
+	meta := queueMeta{Priority: queuePriority(priority), Every: every}
+	if after != "" {
+		meta.After = queueAfter(qClient, after)
+	}
+	if estimate != "" {
+		meta.Estimate = queueEstimate(estimate)
+	}
+	if under != "" && parent != "" {
+		fmt.Printf("Error: use either --under or --parent\n")
+		os.Exit(exitUsage)
+	}
+	if under != "" {
+		meta.Under = queueUnder(ctxClient, under)
+	}
+	if parent != "" {
+		meta.Parent = queueId(qClient, parent)
+	}
+	if due != "" {
+		meta.Due = queueDue(due)
+	}
+	if every != "" {
+		queueEvery(every)
+		setFirstDue(&meta)
+	}
 	fmt.Println("add to queue")
 	q := ctxclient.Queue{}
 	name := getLine("new queue name: ", true)
 	q.Name = name
-	addQueueNotes(&q, "Enter notes for this queue (endline with \\ for multiline): ")
+	addQueueNotes(&q, "Enter notes for this queue (endline with \\ for multiline): ", from, noteSourceManual)
+	setQueueMeta(&q, meta)
 	addQueue := confirm("add to queue? [Y/n]: ", "y")
 	if addQueue {
 		newQueueId, err := qClient.UpdateQueue(&q)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 		output = fmt.Sprintf("\nadded queue: %s\nwith id: %s\n", q.Name, newQueueId)
 	} else {
@@ -651,16 +554,16 @@ func addQueue(qClient *ctxclient.QueueClient) string {
 func doQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, args []string) string {
 	output := ""
 	c := ctxclient.Context{}
-	args = args[1:]
+	from, args := noteSourceFlag(args, false)
 	if len(args) == 0 {
 		fmt.Printf("Error: missing queueId\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 	qId := args[0]
 	q, err := qClient.GetQueue(qId)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if q.Started != "" {
 		fmt.Printf("queue '%s' already started\n", q.Name)
@@ -669,55 +572,75 @@ func doQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient,
 		}
 		os.Exit(0)
 	}
+	checkBlockers(qClient, ctxClient, q)
 	fmt.Printf("starting queue '%s'\nname: %s\n", qId, q.Name)
 
 	c.Name = fmt.Sprintf("%s | queue", q.Name)
-	parentId := getLine("parentId [optional]: ", false)
+	parentId := queueParentContext(qClient, q, map[string]ctxclient.Queue{})
+	if parentId != "" {
+		fmt.Printf("parentId: %s\n", parentId)
+	} else {
+		parentId = getLine("parentId [optional]: ", false)
+	}
 	if len(parentId) > 0 {
 		c.ParentId = parentId
 	}
-	if !isNullJSON(q.Notes) {
-		previous := []string{}
-		err := json.Unmarshal([]byte(q.Notes), &previous)
+	previous, _, err := splitQueueMeta(q.Notes)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(previous) > 0 {
+		qNoteString, err := jsonMarshalIndent(displayNotes(queueNotes(q)), false)
+		// qNoteString, err := json.MarshalIndent(q.Notes, "", "  ")
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
-		qNoteString, err := jsonMarshalIndent(q.Notes, false)
-		// qNoteString, err := json.MarshalIndent(q.Notes, "", "  ")
+		fmt.Printf("notes from queue:\n%s\n", string(qNoteString))
+		// keep track of which notes came from the queue
+		previous, err = carryChecklists(stampNotes(previous, noteSourceQueue, q.Created))
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
-		fmt.Printf("notes from queue:\n%s\n", string(qNoteString))
-		combineNotes(&c, previous, "add note (endline with \\ for multiline): ")
+		combineNotes(&c, previous, "add note (endline with \\ for multiline): ", from, noteSourceManual)
 	} else {
-		addNotes(&c, "Enter notes for this context (endline with \\ for multiline): ")
+		addNotes(&c, "Enter notes for this context (endline with \\ for multiline): ", from, noteSourceManual)
+	}
+	err = addEstimateNote(&c, q)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
 	}
 	_, err = stringifyContext(&c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output, err = stringifyContext(&c)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	fmt.Printf("new context:\n%s\n", output)
 	makeSwitch := confirm("make switch? [Y/n]: ", "y")
 	if makeSwitch {
+		endPause(ctxClient, "current")
 		newContextId, err := ctxClient.UpdateContext(&c)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 		_, err = qClient.StartQueue(qId, newContextId)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
+		recordQueue(queueEvent{State: queueStateStarted, QueueId: qId, Name: q.Name, ContextId: newContextId})
+		switchDocsBranch(ctxClient, &c)
 		output = fmt.Sprintf("\nupdated context: %s\nwith contextId: %s\n", c.Name, newContextId)
+		output += repeatQueue(qClient, q)
 	} else {
 		output = "cancelled"
 	}
@@ -726,50 +649,69 @@ func doQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient,
 
 func addNoteQueue(qClient *ctxclient.QueueClient, args []string) string {
 	output := ""
-	args = args[1:]
+	structured, args := structuredNoteFlags(args)
+	from, args := noteSourceFlag(args, true)
 	if len(args) == 0 {
 		fmt.Printf("Error: missing queueId\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 	qId := args[0]
 	q, err := qClient.GetQueue(qId)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+	if len(structured) > 0 {
+		setQueueNotes(q, stampNotes(structured, noteSourceManual, ""))
+	} else {
+		addQueueNotes(q, "add note (endline with \\ for multiline): ", from, noteSourceManual)
 	}
-	addQueueNotes(q, "add note (endline with \\ for multiline): ")
 	_, err = stringifyQueue(q)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	newQueueId, err := qClient.UpdateQueue(q)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	output = fmt.Sprintf("added note to '%s'\nwith queueId: %s\n", q.Name, newQueueId)
 	return output
 }
 
 func closeQueue(qClient *ctxclient.QueueClient, args []string) string {
-	output := ""
-	args = args[1:]
-	if len(args) == 0 {
-		fmt.Printf("Error: missing queueId\n")
-		os.Exit(1)
-	}
-	qId := args[0]
-	_, err := qClient.StartQueue(qId, "")
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+	return finishQueue(qClient, args, queueStateClosed)
+}
+
+func withReports(ctxClient *ctxclient.ContextClient, qClient *ctxclient.QueueClient, run func() string) string {
+	outputChan := make(chan string)
+	versionCheckChan := make(chan string)
+	overdueChan := make(chan string)
+	go func(outputChan chan string) {
+		output := run()
+		outputChan <- output
+	}(outputChan)
+	go func(versionCheckChan chan string) {
+		versionCheck := checkVersions(ctxClient, false)
+		versionCheckChan <- versionCheck
+	}(versionCheckChan)
+	go func(overdueChan chan string) {
+		overdueChan <- checkOverdue(qClient)
+	}(overdueChan)
+	output := <-outputChan
+	versionCheck := <-versionCheckChan
+	if len(versionCheck) > 0 {
+		output += versionCheck
+	}
+	overdue := <-overdueChan
+	if len(overdue) > 0 {
+		output += overdue
 	}
-	output = fmt.Sprintf("queue %s closed", qId)
 	return output
 }
 
@@ -782,7 +724,7 @@ func checkVersions(ctxClient *ctxclient.ContextClient, reportVersion bool) strin
 		ctxapiVersion, err := ctxClient.GetVersion()
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 		latestCtxapi := getLatestRelease("ctxapi")
 		ctxapiOutput := compareVersions("ctxapi", ctxapiVersion, latestCtxapi)
@@ -818,11 +760,36 @@ func getQueryWindow() (string, string, string) {
 	return start, end, unit
 }
 
+func parseSince(since string) (string, string, time.Time, error) {
+	re := regexp.MustCompile(`^(\d+)([a-zA-Z])$`)
+	match := re.FindStringSubmatch(since)
+	if match == nil || timeUnits[match[2]] == "" {
+		return "", "", time.Time{}, fmt.Errorf("invalid window '%s', use a number and one of (%s)", since, displayUnits(timeUnits))
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	unitDurations := map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+		"M": 30 * 24 * time.Hour,
+		"y": 365 * 24 * time.Hour,
+	}
+	start := time.Now().UTC().Add(-time.Duration(n) * unitDurations[match[2]])
+	return match[1], match[2], start, nil
+}
+
 func stringifyContext(c *ctxclient.Context) (string, error) {
 	if isNullJSON(c.Notes) {
 		c.Notes = []byte{}
 	}
-	ctxJson, err := jsonMarshalIndent(c, false)
+	display := *c
+	display.Notes = displayNotes(c.Notes)
+	ctxJson, err := jsonMarshalIndent(display, false)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return "", err
@@ -835,7 +802,8 @@ func stringifyContext(c *ctxclient.Context) (string, error) {
 }
 
 func stringifyQueue(q *ctxclient.Queue) (string, error) {
-	qJson, err := jsonMarshalIndent(q, false)
+	display := displayQueue(*q)
+	qJson, err := jsonMarshalIndent(display, false)
 	// qJson, err := json.MarshalIndent(q, "", "  ")
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -848,7 +816,12 @@ func stringifyQueue(q *ctxclient.Queue) (string, error) {
 }
 
 func stringifyList(c *[]ctxclient.Context) (string, error) {
-	ctxJson, err := jsonMarshalIndent(c, false)
+	display := []ctxclient.Context{}
+	for _, ctx := range *c {
+		ctx.Notes = displayNotes(ctx.Notes)
+		display = append(display, ctx)
+	}
+	ctxJson, err := jsonMarshalIndent(display, false)
 	// ctxJson, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -861,7 +834,7 @@ func stringifyList(c *[]ctxclient.Context) (string, error) {
 }
 
 func stringifyFormatted(c *[]ctxclient.FormattedContext) (string, error) {
-	ctxJson, err := jsonMarshalIndent(c, false)
+	ctxJson, err := jsonMarshalIndent(displayFormatted(*c), false)
 	// ctxJson, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -874,7 +847,11 @@ func stringifyFormatted(c *[]ctxclient.FormattedContext) (string, error) {
 }
 
 func stringifyQueueList(q *[]ctxclient.Queue) (string, error) {
-	qJson, err := jsonMarshalIndent(q, false)
+	display := []queueDisplay{}
+	for _, queue := range *q {
+		display = append(display, displayQueue(queue))
+	}
+	qJson, err := jsonMarshalIndent(display, false)
 	// qJson, err := json.MarshalIndent(q, "", "  ")
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -886,6 +863,27 @@ func stringifyQueueList(q *[]ctxclient.Queue) (string, error) {
 	return string(qJson), nil
 }
 
+type summaryContext struct {
+	ctxclient.FormattedContext
+	Checklist   string           `json:"checklist,omitempty"`
+	SubContexts []summaryContext `json:"subContexts,omitempty"`
+}
+
+func displayFormatted(cs []ctxclient.FormattedContext) []summaryContext {
+	display := []summaryContext{}
+	for _, c := range cs {
+		s := summaryContext{
+			Checklist:   checklistProgress(c.Notes),
+			SubContexts: displayFormatted(c.SubContexts),
+		}
+		c.Notes = displayNotes(c.Notes)
+		c.SubContexts = nil
+		s.FormattedContext = c
+		display = append(display, s)
+	}
+	return display
+}
+
 func printYaml(data []byte) (string, error) {
 	var jsonInterface interface{}
 	err := json.Unmarshal(data, &jsonInterface)
@@ -959,12 +957,16 @@ func confirm(prompt string, def string) bool {
 	return false
 }
 
-func addNotes(c *ctxclient.Context, prompt string) []byte {
-	notes := getMultiLine(prompt)
+func addNotes(c *ctxclient.Context, prompt, from, source string) []byte {
+	notes := stampNotes(getNotes(prompt, from, c.Name, c.Notes), source, "")
+	return setNotes(c, notes)
+}
+
+func setNotes(c *ctxclient.Context, notes []string) []byte {
 	notesJSON, err := jsonMarshal(notes, false)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if !isNullJSON(notesJSON) {
 		c.Notes = notesJSON
@@ -972,13 +974,18 @@ func addNotes(c *ctxclient.Context, prompt string) []byte {
 	return notesJSON
 }
 
-func combineNotes(c *ctxclient.Context, previous []string, prompt string) []byte {
-	notes := getMultiLine(prompt)
+func combineNotes(c *ctxclient.Context, previous []string, prompt, from, source string) []byte {
+	previousJSON, err := jsonMarshal(previous, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	notes := stampNotes(getNotes(prompt, from, c.Name, previousJSON), source, "")
 	previous = append(previous, notes...)
 	notesJSON, err := jsonMarshal(previous, false)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if !isNullJSON(notesJSON) {
 		c.Notes = notesJSON
@@ -986,12 +993,25 @@ func combineNotes(c *ctxclient.Context, previous []string, prompt string) []byte
 	return notesJSON
 }
 
-func addQueueNotes(q *ctxclient.Queue, prompt string) []byte {
-	notes := getMultiLine(prompt)
+func addQueueNotes(q *ctxclient.Queue, prompt, from, source string) []byte {
+	notes := stampNotes(getNotes(prompt, from, q.Name, queueNotes(q)), source, "")
+	return setQueueNotes(q, notes)
+}
+
+func setQueueNotes(q *ctxclient.Queue, notes []string) []byte {
+	// the queue's meta note isn't a user note, keep it whatever the notes are
+	_, meta, err := splitQueueMeta(q.Notes)
+	if err != nil {
+		fmt.Printf("Error: queue '%s': %v\n", q.Name, err)
+		os.Exit(exitError)
+	}
+	if meta != "" {
+		notes = append(notes, meta)
+	}
 	notesJSON, err := jsonMarshal(notes, false)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if !isNullJSON(notesJSON) {
 		q.Notes = notesJSON
@@ -999,6 +1019,48 @@ func addQueueNotes(q *ctxclient.Queue, prompt string) []byte {
 	return notesJSON
 }
 
+func flagValue(args []string, names ...string) (string, []string) {
+	value := ""
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		matched := false
+		for _, name := range names {
+			if args[i] == name {
+				if i+1 >= len(args) {
+					fmt.Printf("Error: missing value for %s\n", name)
+					os.Exit(exitUsage)
+				}
+				value = args[i+1]
+				i++
+				matched = true
+			} else if strings.HasPrefix(args[i], name+"=") {
+				value = strings.TrimPrefix(args[i], name+"=")
+				matched = true
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			rest = append(rest, args[i])
+		}
+	}
+	return value, rest
+}
+
+func hasFlag(args []string, names ...string) (bool, []string) {
+	found := false
+	rest := []string{}
+	for _, arg := range args {
+		if containsString(names, arg) {
+			found = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return found, rest
+}
+
 func getLastHash(sk string) string {
 	if strings.Contains(sk, "#") {
 		ctxTimestamp := strings.Split(sk, "#")
@@ -1040,6 +1102,14 @@ func defaultEnv(envName, defaultValue string) string {
 	return val
 }
 
+func defaultStateDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".ctx"
+	}
+	return filepath.Join(home, ".ctx")
+}
+
 func getLatestRelease(pkg string) string {
 	gitUrl := "https://github.com/charlesrobsampson/" + pkg
 	head, err := http.Head(gitUrl + "/releases/latest")
@@ -1082,57 +1152,3 @@ func stripNonNumbers(s string) float64 {
 	}
 	return output
 }
-
-func findParentDoc(ctxClient *ctxclient.ContextClient, ctxId string) (ctxclient.Document, error) {
-	doc := ctxclient.Document{}
-	c, err := ctxClient.GetContext(ctxId)
-	if err != nil {
-		return doc, err
-	}
-	if c.Document.RealtivePath != "" {
-		return c.Document, nil
-	}
-	if c.ParentId == "" {
-		return doc, nil
-	}
-	return findParentDoc(ctxClient, c.ParentId)
-}
-
-func syncNotes(wg *sync.WaitGroup) {
-	wg.Add(1)
-	defer wg.Done()
-	fmt.Printf("syncing:\n%s\n", CTX_DOCS_PATH)
-	repo, err := git.PlainOpen(CTX_DOCS_PATH)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
-	}
-	worktree, err := repo.Worktree()
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
-	}
-
-	err = worktree.Pull(&git.PullOptions{
-		RemoteName: "origin",
-		Progress:   os.Stdout,
-	})
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		fmt.Printf("Error pulling changes: %v", err)
-	}
-
-	_, err = worktree.Add(".")
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
-	}
-	worktree.Commit("sync notes", &git.CommitOptions{})
-	err = repo.Push(&git.PushOptions{
-		RemoteName: "origin",
-		Progress:   os.Stdout,
-	})
-	if err != nil {
-		fmt.Printf("Error pushing changes: %v\n", err)
-	}
-	// fmt.Printf("worktree: %+v\n", worktree)
-}
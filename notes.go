@@ -0,0 +1,743 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+const (
+	notesFromPrompt = ""
+	notesFromEditor = "edit"
+	notesFromStdin  = "stdin"
+	// everything above the scissors line is dropped, so notes can still
+	// contain lines starting with # like shell or python snippets
+	noteScissors = "# ------------------------ >8 ------------------------"
+	// a line with only this on it separates notes in the editor or stdin
+	noteSeparator = "---"
+)
+
+func noteSourceFlag(args []string, allowStdin bool) (string, []string) {
+	from := notesFromPrompt
+	rest := []string{}
+	for _, arg := range args {
+		switch {
+		case arg == "--edit" || arg == "-e":
+			from = notesFromEditor
+		case arg == "-" && allowStdin:
+			from = notesFromStdin
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return from, rest
+}
+
+func noteArgs(args []string) ([]string, string) {
+	notes, args := structuredNoteFlags(args)
+	from, args := noteSourceFlag(args, true)
+	text := strings.TrimSpace(strings.Join(args, " "))
+	if text == "" {
+		return notes, from
+	}
+	if from != notesFromPrompt {
+		usageError("give the note as text or with --edit or -, not both", "", "ctx help note")
+	}
+	return append(notes, text), from
+}
+
+func getNotes(prompt, from, name string, existing json.RawMessage) []string {
+	switch from {
+	case notesFromEditor:
+		return composeNotes(name, existing)
+	case notesFromStdin:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Printf("Error reading notes from stdin: %v\n", err)
+			os.Exit(exitError)
+		}
+		return parseNoteText(string(data))
+	}
+	return getMultiLine(prompt)
+}
+
+func composeNotes(name string, existing json.RawMessage) []string {
+	return parseNoteText(editText(noteHeader(name, existing), ""))
+}
+
+func editText(header, body string) string {
+	f, err := os.CreateTemp("", "ctx-note-*.md")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(header + noteScissors + "\n" + body)
+	f.Close()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	err = openInEditor(f.Name(), strings.Count(header, "\n")+2)
+	if err != nil {
+		fmt.Printf("Error opening editor: %v\n", err)
+		os.Exit(exitError)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	text := string(data)
+	if i := strings.Index(text, noteScissors); i >= 0 {
+		text = text[i+len(noteScissors):]
+	}
+	return text
+}
+
+func noteHeader(name string, existing json.RawMessage) string {
+	header := ""
+	if name != "" {
+		header += fmt.Sprintf("# notes for '%s'\n", name)
+	}
+	previous, err := readNotes(existing)
+	if err == nil && len(previous) > 0 {
+		header += "# existing notes:\n"
+		for _, n := range previous {
+			stamp := ""
+			if n.At != "" {
+				stamp = fmt.Sprintf(" (%s %s)", n.At, n.Source)
+			}
+			header += "#   - " + strings.ReplaceAll(noteString(n), "\n", "\n#     ") + stamp + "\n"
+		}
+	}
+	header += fmt.Sprintf("# write notes below the line, separate notes with a line of just %s\n", noteSeparator)
+	header += "# save and close the editor when you're done, leave it empty to add nothing\n"
+	return header
+}
+
+func parseNoteText(text string) []string {
+	notes := []string{}
+	current := []string{}
+	addCurrent := func() {
+		note := strings.TrimSpace(strings.Join(current, "\n"))
+		if note != "" {
+			notes = append(notes, note)
+		}
+		current = []string{}
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == noteSeparator {
+			addCurrent()
+			continue
+		}
+		current = append(current, strings.TrimRight(line, " \t"))
+	}
+	addCurrent()
+	return notes
+}
+
+const (
+	noteSourceManual = "manual"
+	noteSourceQueue  = "queue"
+	noteSourceResume = "resume"
+	noteSourceImport = "import"
+	// notes ctx writes on its own rather than ones typed in by the user
+	noteSourceHook = "hook"
+
+	noteTypeText      = "text"
+	noteTypeLink      = "link"
+	noteTypeChecklist = "checklist"
+	noteTypeKV        = "kv"
+	// ctx's own data for a queue item, see queue.go
+	noteTypeMeta = "meta"
+)
+
+// notes are still stored as strings since ctxclient merges them as strings
+// in summaries, structured notes as their JSON encoding
+type note struct {
+	Type   string            `json:"type"`
+	At     string            `json:"at,omitempty"`
+	Source string            `json:"source,omitempty"`
+	Text   string            `json:"text,omitempty"`
+	URL    string            `json:"url,omitempty"`
+	Title  string            `json:"title,omitempty"`
+	Items  []checklistItem   `json:"items,omitempty"`
+	Values map[string]string `json:"values,omitempty"`
+	Meta   *queueMeta        `json:"meta,omitempty"`
+}
+
+type checklistItem struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+func (item *checklistItem) UnmarshalJSON(data []byte) error {
+	text := ""
+	if json.Unmarshal(data, &text) == nil {
+		item.Text = text
+		return nil
+	}
+	type plain checklistItem
+	return json.Unmarshal(data, (*plain)(item))
+}
+
+func parseNote(s string) note {
+	trimmed := strings.TrimSpace(s)
+	if strings.HasPrefix(trimmed, "{") {
+		n := note{}
+		if json.Unmarshal([]byte(trimmed), &n) == nil && validateNote(n) == nil {
+			return n
+		}
+	}
+	return note{Type: noteTypeText, Text: s}
+}
+
+func encodeNote(n note) (string, error) {
+	if isPlainNote(n) {
+		return n.Text, nil
+	}
+	err := validateNote(n)
+	if err != nil {
+		return "", err
+	}
+	data, err := jsonMarshal(n, false)
+	return strings.TrimSpace(string(data)), err
+}
+
+func validateNote(n note) error {
+	switch n.Type {
+	case noteTypeText:
+		if n.Text == "" {
+			return fmt.Errorf("text note needs text")
+		}
+	case noteTypeLink:
+		u, err := url.Parse(n.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("link note needs a full url, got '%s'", n.URL)
+		}
+	case noteTypeChecklist:
+		if len(n.Items) == 0 {
+			return fmt.Errorf("checklist note needs at least one item")
+		}
+		for i, item := range n.Items {
+			if strings.TrimSpace(item.Text) == "" {
+				return fmt.Errorf("checklist item %d is empty", i+1)
+			}
+		}
+	case noteTypeKV:
+		if len(n.Values) == 0 {
+			return fmt.Errorf("kv note needs at least one value")
+		}
+		for k := range n.Values {
+			if strings.TrimSpace(k) == "" {
+				return fmt.Errorf("kv note has an empty key")
+			}
+		}
+	case noteTypeMeta:
+		if n.Meta == nil {
+			return fmt.Errorf("meta note is empty")
+		}
+	case "":
+		return fmt.Errorf("note is missing a type (%s)", strings.Join(noteTypes(), ", "))
+	default:
+		return fmt.Errorf("unknown note type '%s' (%s)", n.Type, strings.Join(noteTypes(), ", "))
+	}
+	return nil
+}
+
+func isPlainNote(n note) bool {
+	return n.Type == noteTypeText && n.At == "" && n.Source == ""
+}
+
+func stampNotes(notes []string, source, at string) []string {
+	if at == "" {
+		at = time.Now().UTC().Format(ctxclient.SkDateFormat)
+	}
+	stamped := []string{}
+	for _, s := range notes {
+		n := parseNote(s)
+		if n.At == "" {
+			n.At = at
+		}
+		if n.Source == "" {
+			n.Source = source
+		}
+		encoded, err := encodeNote(n)
+		if err != nil {
+			encoded = s
+		}
+		stamped = append(stamped, encoded)
+	}
+	return stamped
+}
+
+func noteTypes() []string {
+	return []string{noteTypeText, noteTypeLink, noteTypeChecklist, noteTypeKV}
+}
+
+func readNotes(raw json.RawMessage) ([]note, error) {
+	notes := []note{}
+	if isNullJSON(raw) || len(bytes.TrimSpace(raw)) == 0 {
+		return notes, nil
+	}
+	entries := []json.RawMessage{}
+	err := json.Unmarshal(raw, &entries)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		s := ""
+		// entries written straight to the api can be objects
+		if json.Unmarshal(entry, &s) != nil {
+			s = string(entry)
+		}
+		notes = append(notes, parseNote(s))
+	}
+	return notes, nil
+}
+
+func noteStrings(raw json.RawMessage) ([]string, error) {
+	notes := []string{}
+	if isNullJSON(raw) || len(bytes.TrimSpace(raw)) == 0 {
+		return notes, nil
+	}
+	entries := []json.RawMessage{}
+	err := json.Unmarshal(raw, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("could not read notes: %v", err)
+	}
+	for _, entry := range entries {
+		s := ""
+		if json.Unmarshal(entry, &s) != nil {
+			s = string(entry)
+		}
+		notes = append(notes, s)
+	}
+	return notes, nil
+}
+
+func displayNotes(raw json.RawMessage) json.RawMessage {
+	notes, err := readNotes(raw)
+	if err != nil || len(notes) == 0 {
+		return raw
+	}
+	display := []interface{}{}
+	for _, n := range notes {
+		if isPlainNote(n) {
+			display = append(display, n.Text)
+		} else {
+			display = append(display, n)
+		}
+	}
+	data, err := jsonMarshal(display, false)
+	if err != nil {
+		return raw
+	}
+	return data
+}
+
+func noteString(n note) string {
+	switch n.Type {
+	case noteTypeLink:
+		if n.Title != "" {
+			return fmt.Sprintf("%s <%s>", n.Title, n.URL)
+		}
+		return n.URL
+	case noteTypeChecklist:
+		lines := []string{}
+		for _, item := range n.Items {
+			box := "[ ]"
+			if item.Done {
+				box = "[x]"
+			}
+			lines = append(lines, fmt.Sprintf("%s %s", box, item.Text))
+		}
+		return strings.Join(lines, "\n")
+	case noteTypeKV:
+		keys := []string{}
+		for k := range n.Values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		lines := []string{}
+		for _, k := range keys {
+			lines = append(lines, fmt.Sprintf("%s: %s", k, n.Values[k]))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return n.Text
+}
+
+func structuredNoteFlags(args []string) ([]string, []string) {
+	notes := []string{}
+	rest := []string{}
+	kv := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg != "--json" && arg != "--kv" {
+			rest = append(rest, arg)
+			continue
+		}
+		if i+1 >= len(args) {
+			fmt.Printf("Error: missing value for %s\n", arg)
+			os.Exit(exitUsage)
+		}
+		i++
+		if arg == "--kv" {
+			key, value, ok := strings.Cut(args[i], "=")
+			if !ok {
+				fmt.Printf("Error: --kv expects key=value, got '%s'\n", args[i])
+				os.Exit(exitUsage)
+			}
+			kv[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			continue
+		}
+		n := note{}
+		err := json.Unmarshal([]byte(args[i]), &n)
+		if err != nil {
+			fmt.Printf("Error: invalid json note: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		if n.Type == noteTypeMeta {
+			fmt.Printf("Error: invalid note: unknown note type '%s' (%s)\n", n.Type, strings.Join(noteTypes(), ", "))
+			os.Exit(exitUsage)
+		}
+		encoded, err := encodeNote(n)
+		if err != nil {
+			fmt.Printf("Error: invalid note: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		notes = append(notes, encoded)
+	}
+	if len(kv) > 0 {
+		encoded, err := encodeNote(note{Type: noteTypeKV, Values: kv})
+		if err != nil {
+			fmt.Printf("Error: invalid note: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		notes = append(notes, encoded)
+	}
+	return notes, rest
+}
+
+type noteEntry struct {
+	At        string `json:"at"`
+	Source    string `json:"source,omitempty"`
+	Context   string `json:"context"`
+	ContextId string `json:"contextId"`
+	Type      string `json:"type"`
+	Note      string `json:"note"`
+}
+
+func listNotes(ctxClient *ctxclient.ContextClient, current *ctxclient.Context, args []string) string {
+	since := "1d"
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--since" && i+1 < len(args) {
+			since = args[i+1]
+			i++
+		}
+	}
+	start, unit, cutoff, err := parseSince(since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	cs, err := ctxClient.ListContexts(ctxclient.QSParams{
+		"start": start,
+		"end":   "0",
+		"unit":  unit,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	contexts := *cs
+	// notes can be added to the current context long after it started
+	found := false
+	for _, c := range contexts {
+		found = found || c.ContextId == current.ContextId
+	}
+	if !found && current.ContextId != "" {
+		contexts = append(contexts, *current)
+	}
+
+	entries := []noteEntry{}
+	for _, c := range contexts {
+		notes, err := readNotes(c.Notes)
+		if err != nil {
+			continue
+		}
+		for _, n := range notes {
+			at := n.At
+			// notes from before they were stamped
+			if at == "" {
+				at = c.Created
+			}
+			t, err := time.Parse(ctxclient.SkDateFormat, at)
+			if err != nil || t.Before(cutoff) {
+				continue
+			}
+			entries = append(entries, noteEntry{
+				At:        at,
+				Source:    n.Source,
+				Context:   c.Name,
+				ContextId: c.ContextId,
+				Type:      n.Type,
+				Note:      noteString(n),
+			})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At < entries[j].At
+	})
+	if len(entries) == 0 {
+		return fmt.Sprintf("no notes in the last %s", since)
+	}
+	output, err := stringifyNoteEntries(&entries)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return output
+}
+
+func stringifyNoteEntries(n *[]noteEntry) (string, error) {
+	nJson, err := jsonMarshalIndent(n, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return "", err
+	}
+	if EXPORT_TYPE == "yaml" {
+		return printYaml(nJson)
+	}
+	return string(nJson), nil
+}
+
+func noteCtx(ctxClient *ctxclient.ContextClient, current *ctxclient.Context, args []string) string {
+	if len(args) == 0 {
+		return addNoteCtx(ctxClient, current, args)
+	}
+	cmd := args[0]
+	switch cmd {
+	case "ls", "list":
+		c := noteContext(ctxClient, current, args[1:])
+		return listNoteEntries(c.Name, c.Notes)
+	case "edit", "rm", "remove":
+		if len(args) < 2 {
+			fmt.Printf("Error: missing note index\n")
+			os.Exit(exitUsage)
+		}
+		index := noteIndex(args[1])
+		c := noteContext(ctxClient, current, args[2:])
+		changed := false
+		if cmd == "edit" {
+			c.Notes, changed = editNoteAt(c.Name, c.Notes, index)
+		} else {
+			c.Notes, changed = removeNoteAt(c.Name, c.Notes, index)
+		}
+		if !changed {
+			return "cancelled"
+		}
+		_, err := ctxClient.UpdateContext(c)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		return fmt.Sprintf("updated notes on '%s'\nwith contextId: %s\n", c.Name, c.ContextId)
+	}
+	return addNoteCtx(ctxClient, current, args)
+}
+
+func noteContext(ctxClient *ctxclient.ContextClient, current *ctxclient.Context, args []string) *ctxclient.Context {
+	if len(args) == 0 {
+		if current.ContextId == "" {
+			fmt.Println("no current context")
+			os.Exit(exitError)
+		}
+		return current
+	}
+	c, err := ctxClient.GetContext(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return c
+}
+
+func noteQueue(qClient *ctxclient.QueueClient, args []string) string {
+	if len(args) == 0 {
+		return addNoteQueue(qClient, args)
+	}
+	cmd := args[0]
+	switch cmd {
+	case "ls", "list", "edit", "rm", "remove":
+		if len(args) < 2 {
+			fmt.Printf("Error: missing queueId\n")
+			os.Exit(exitUsage)
+		}
+		q, err := qClient.GetQueue(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		notes := queueNotes(q)
+		if cmd == "ls" || cmd == "list" {
+			return listNoteEntries(q.Name, notes)
+		}
+		if len(args) < 3 {
+			fmt.Printf("Error: missing note index\n")
+			os.Exit(exitUsage)
+		}
+		index := noteIndex(args[2])
+		changed := false
+		if cmd == "edit" {
+			notes, changed = editNoteAt(q.Name, notes, index)
+		} else {
+			notes, changed = removeNoteAt(q.Name, notes, index)
+		}
+		if !changed {
+			return "cancelled"
+		}
+		edited, err := noteStrings(notes)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		setQueueNotes(q, edited)
+		_, err = qClient.UpdateQueue(q)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		return fmt.Sprintf("updated notes on '%s'\nwith queueId: %s\n", q.Name, q.Id)
+	}
+	return addNoteQueue(qClient, args)
+}
+
+func noteIndex(arg string) int {
+	index, err := strconv.Atoi(arg)
+	if err != nil || index < 1 {
+		fmt.Printf("Error: invalid note index '%s', use the number from note ls\n", arg)
+		os.Exit(exitUsage)
+	}
+	return index
+}
+
+func listNoteEntries(name string, raw json.RawMessage) string {
+	notes, err := readNotes(raw)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(notes) == 0 {
+		return fmt.Sprintf("no notes on '%s'", name)
+	}
+	output := fmt.Sprintf("notes on '%s':\n", name)
+	for i, n := range notes {
+		output += noteListing(i+1, n)
+	}
+	return output
+}
+
+func noteListing(index int, n note) string {
+	prefix := fmt.Sprintf("%d. ", index)
+	listing := prefix + strings.ReplaceAll(noteString(n), "\n", "\n"+strings.Repeat(" ", len(prefix)))
+	if n.At != "" {
+		listing += fmt.Sprintf("  (%s %s)", n.At, n.Source)
+	}
+	return listing + "\n"
+}
+
+func editNoteAt(name string, raw json.RawMessage, index int) (json.RawMessage, bool) {
+	notes := notesAt(raw, index)
+	n := notes[index-1]
+	header := fmt.Sprintf("# editing note %d on '%s'\n", index, name)
+	edited := n
+	if n.Type == noteTypeText {
+		header += "# save and close the editor when you're done\n"
+		edited.Text = strings.TrimSpace(editText(header, n.Text))
+		if edited.Text == "" {
+			fmt.Println("note is empty, use rm to remove it")
+			return raw, false
+		}
+	} else {
+		header += "# edit the json below, save and close the editor when you're done\n"
+		body, err := jsonMarshalIndent(n, false)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		edited = note{}
+		err = json.Unmarshal([]byte(editText(header, string(body))), &edited)
+		if err != nil {
+			fmt.Printf("Error: invalid json note: %v\n", err)
+			os.Exit(exitError)
+		}
+		err = validateNote(edited)
+		if err != nil {
+			fmt.Printf("Error: invalid note: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	if noteString(edited) == noteString(n) {
+		fmt.Println("note unchanged")
+		return raw, false
+	}
+	fmt.Printf("before:\n%safter:\n%s", noteListing(index, n), noteListing(index, edited))
+	if !confirm("save changes? [Y/n]: ", "y") {
+		return raw, false
+	}
+	notes[index-1] = edited
+	return encodeNotes(notes), true
+}
+
+func removeNoteAt(name string, raw json.RawMessage, index int) (json.RawMessage, bool) {
+	notes := notesAt(raw, index)
+	fmt.Printf("removing from '%s':\n%s", name, noteListing(index, notes[index-1]))
+	if !confirm("remove note? [y/N]: ", "n") {
+		return raw, false
+	}
+	notes = append(notes[:index-1], notes[index:]...)
+	return encodeNotes(notes), true
+}
+
+func notesAt(raw json.RawMessage, index int) []note {
+	notes, err := readNotes(raw)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if index > len(notes) {
+		fmt.Printf("Error: no note %d, there are %d notes\n", index, len(notes))
+		os.Exit(exitUsage)
+	}
+	return notes
+}
+
+func encodeNotes(notes []note) json.RawMessage {
+	encoded := []string{}
+	for _, n := range notes {
+		s, err := encodeNote(n)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		encoded = append(encoded, s)
+	}
+	notesJSON, err := jsonMarshal(encoded, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return notesJSON
+}
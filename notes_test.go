@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNoteText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{}},
+		{"  \n\n", []string{}},
+		{"one note", []string{"one note"}},
+		{"first\n---\nsecond", []string{"first", "second"}},
+		{"line one\nline two  \n\n", []string{"line one\nline two"}},
+		{"first\r\n---\r\nsecond\r\n", []string{"first", "second"}},
+		{"---\nfirst\n  ---  \n---\nsecond\n---", []string{"first", "second"}},
+		{"# a comment\n----\n--- not a separator", []string{"# a comment\n----\n--- not a separator"}},
+		{"```\nindented\n\tcode\n```", []string{"```\nindented\n\tcode\n```"}},
+	}
+	for _, tt := range tests {
+		if got := parseNoteText(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseNoteText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNoteArgs(t *testing.T) {
+	tests := []struct {
+		args  []string
+		notes []string
+		from  string
+	}{
+		{[]string{}, []string{}, notesFromPrompt},
+		{[]string{"-e"}, []string{}, notesFromEditor},
+		{[]string{"-"}, []string{}, notesFromStdin},
+		{[]string{"fixed", "the", "build"}, []string{"fixed the build"}, notesFromPrompt},
+		{[]string{"fixed the build"}, []string{"fixed the build"}, notesFromPrompt},
+		{[]string{"--force push", "is", "off"}, []string{"--force push is off"}, notesFromPrompt},
+	}
+	for _, tt := range tests {
+		notes, from := noteArgs(tt.args)
+		if !reflect.DeepEqual(notes, tt.notes) || from != tt.from {
+			t.Errorf("noteArgs(%q) = %q %q, want %q %q", tt.args, notes, from, tt.notes, tt.from)
+		}
+	}
+
+	notes, _ := noteArgs([]string{"--kv", "tag=deep-work", "reading"})
+	if len(notes) != 2 || parseNote(notes[0]).Type != noteTypeKV || notes[1] != "reading" {
+		t.Errorf("noteArgs with --kv and text = %q, want the kv note then the text", notes)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type span struct {
+	Start time.Time
+	End   time.Time
+}
+
+// breaks are kept on the context as a kv note {paused, resumed} and left out
+// of the time spent on it.
+func pauseCtx(ctxClient *ctxclient.ContextClient, current *ctxclient.Context) string {
+	if current.ContextId == "" {
+		return "no current context to pause"
+	}
+	c, err := ctxClient.GetContext(current.ContextId)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if paused, ok := pausedSince(c); ok {
+		return fmt.Sprintf("'%s' has been paused since %s, ctx unpause to carry on", c.Name, paused.Local().Format("15:04"))
+	}
+	now := time.Now().UTC().Format(ctxclient.SkDateFormat)
+	encoded, err := encodeNote(note{
+		Type:   noteTypeKV,
+		At:     now,
+		Source: noteSourceHook,
+		Values: map[string]string{"paused": now},
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	notes, err := noteStrings(c.Notes)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	setNotes(c, append(notes, encoded))
+	_, err = ctxClient.UpdateContext(c)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return fmt.Sprintf("paused '%s'", c.Name)
+}
+
+func unpauseCtx(ctxClient *ctxclient.ContextClient, current *ctxclient.Context) string {
+	if current.ContextId == "" {
+		return "no current context to unpause"
+	}
+	c, err := ctxClient.GetContext(current.ContextId)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	paused, ok, err := resumeNotes(c)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if !ok {
+		return fmt.Sprintf("'%s' isn't paused", c.Name)
+	}
+	_, err = ctxClient.UpdateContext(c)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return fmt.Sprintf("back on '%s' after a %s break", c.Name, formatMinutes(time.Since(paused).Minutes()))
+}
+
+func endPause(ctxClient *ctxclient.ContextClient, contextId string) {
+	c, err := ctxClient.GetContext(contextId)
+	if err != nil || c.ContextId == "" {
+		return
+	}
+	_, ok, err := resumeNotes(c)
+	if err == nil && ok {
+		_, err = ctxClient.UpdateContext(c)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+func resumeNotes(c *ctxclient.Context) (time.Time, bool, error) {
+	notes, err := noteStrings(c.Notes)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for i := len(notes) - 1; i >= 0; i-- {
+		n := parseNote(notes[i])
+		// breaks from before it was created were taken on an earlier copy
+		if !isOpenPause(n) || n.Values["paused"] < c.Created {
+			continue
+		}
+		paused, _ := time.Parse(ctxclient.SkDateFormat, n.Values["paused"])
+		n.Values["resumed"] = time.Now().UTC().Format(ctxclient.SkDateFormat)
+		encoded, err := encodeNote(n)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		notes[i] = encoded
+		setNotes(c, notes)
+		return paused, true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+func isOpenPause(n note) bool {
+	return n.Type == noteTypeKV && n.Values["paused"] != "" && n.Values["resumed"] == ""
+}
+
+func pausedSince(c *ctxclient.Context) (time.Time, bool) {
+	notes, err := readNotes(c.Notes)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for i := len(notes) - 1; i >= 0; i-- {
+		if isOpenPause(notes[i]) && notes[i].Values["paused"] >= c.Created {
+			paused, err := time.Parse(ctxclient.SkDateFormat, notes[i].Values["paused"])
+			return paused, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+func contextPauses(raw json.RawMessage, start, end time.Time) []span {
+	pauses := []span{}
+	notes, err := readNotes(raw)
+	if err != nil {
+		return pauses
+	}
+	for _, n := range notes {
+		if n.Type != noteTypeKV || n.Values["paused"] == "" {
+			continue
+		}
+		// resumed contexts carry the notes of the copy they came from, so
+		// breaks before start were taken on that copy
+		paused, err := time.Parse(ctxclient.SkDateFormat, n.Values["paused"])
+		if err != nil || paused.Before(start) || !paused.Before(end) {
+			continue
+		}
+		stop := end
+		if n.Values["resumed"] != "" {
+			stop, err = time.Parse(ctxclient.SkDateFormat, n.Values["resumed"])
+			if err != nil {
+				continue
+			}
+		}
+		pauses = append(pauses, span{Start: paused, End: stop})
+	}
+	return pauses
+}
+
+func pausedMinutes(raw json.RawMessage, start, end time.Time) float64 {
+	total := 0.0
+	for _, p := range contextPauses(raw, start, end) {
+		if p.End.After(end) {
+			p.End = end
+		}
+		if p.End.After(p.Start) {
+			total += p.End.Sub(p.Start).Minutes()
+		}
+	}
+	return total
+}
+
+func activeSpans(raw json.RawMessage, start, end time.Time) []span {
+	spans := []span{{Start: start, End: end}}
+	for _, p := range contextPauses(raw, start, end) {
+		split := []span{}
+		for _, s := range spans {
+			if !p.Start.Before(s.End) || !p.End.After(s.Start) {
+				split = append(split, s)
+				continue
+			}
+			if p.Start.After(s.Start) {
+				split = append(split, span{Start: s.Start, End: p.Start})
+			}
+			if p.End.Before(s.End) {
+				split = append(split, span{Start: p.End, End: s.End})
+			}
+		}
+		spans = split
+	}
+	return spans
+}
+
+func excludePauses(cs []ctxclient.FormattedContext, contexts []ctxclient.Context) float64 {
+	byId := map[string]ctxclient.Context{}
+	for _, c := range contexts {
+		byId[c.ContextId] = c
+	}
+	removed := 0.0
+	now := time.Now()
+	for i := range cs {
+		c := &cs[i]
+		// parents add up the time of their subcontexts
+		paused := excludePauses(c.SubContexts, contexts)
+		// summaries merge resumed copies under the contextId of the first,
+		// so the breaks are worked out on each copy
+		if first, ok := byId[c.ContextId]; ok {
+			for _, other := range contexts {
+				if other.Name != first.Name || other.ParentId != first.ParentId {
+					continue
+				}
+				if created, completed, ok := contextSpan(other, now); ok {
+					paused += pausedMinutes(other.Notes, created, completed)
+				}
+			}
+		}
+		c.TimeSpent.Time = math.Max(0, float64(int((c.TimeSpent.Time-paused)*100+0.5))/100)
+		removed += paused
+	}
+	return removed
+}
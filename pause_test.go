@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+func pauseNotes(t *testing.T, pauses ...[2]string) json.RawMessage {
+	notes := []string{}
+	for _, p := range pauses {
+		values := map[string]string{"paused": p[0]}
+		if p[1] != "" {
+			values["resumed"] = p[1]
+		}
+		encoded, err := encodeNote(note{Type: noteTypeKV, At: p[0], Source: noteSourceHook, Values: values})
+		if err != nil {
+			t.Fatal(err)
+		}
+		notes = append(notes, encoded)
+	}
+	raw, err := json.Marshal(notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestExcludePauses(t *testing.T) {
+	// worked 9-10 and resumed 13-14, paused at 9:30 and never unpaused. the
+	// resumed copy carries the first one's notes
+	open := pauseNotes(t, [2]string{"2024-03-13T09:30:00Z", ""})
+	resumed := []ctxclient.Context{
+		{Name: "work", ContextId: "2024-03-13T09:00:00Z", Created: "2024-03-13T09:00:00Z", Completed: "2024-03-13T10:00:00Z", Notes: open},
+		{Name: "work", ContextId: "2024-03-13T13:00:00Z", Created: "2024-03-13T13:00:00Z", Completed: "2024-03-13T14:00:00Z", Notes: open},
+	}
+	// a sub context with a 15 minute break under a parent with a 10 minute one
+	nested := []ctxclient.Context{
+		{Name: "parent", ContextId: "2024-03-13T15:00:00Z", Created: "2024-03-13T15:00:00Z", Completed: "2024-03-13T15:30:00Z",
+			Notes: pauseNotes(t, [2]string{"2024-03-13T15:05:00Z", "2024-03-13T15:15:00Z"})},
+		{Name: "kid", ParentId: "2024-03-13T15:00:00Z", ContextId: "2024-03-13T15:30:00Z", Created: "2024-03-13T15:30:00Z", Completed: "2024-03-13T16:30:00Z",
+			Notes: pauseNotes(t, [2]string{"2024-03-13T15:45:00Z", "2024-03-13T16:00:00Z"})},
+	}
+	// a break that outlasts what the summary counted
+	short := []ctxclient.Context{
+		{Name: "short", ContextId: "2024-03-13T17:00:00Z", Created: "2024-03-13T17:00:00Z", Completed: "2024-03-13T17:30:00Z",
+			Notes: pauseNotes(t, [2]string{"2024-03-13T17:00:00Z", ""})},
+	}
+	tests := []struct {
+		name     string
+		contexts []ctxclient.Context
+		summary  []ctxclient.FormattedContext
+		want     []float64
+		removed  float64
+	}{
+		{
+			name:     "open break on a resumed context",
+			contexts: resumed,
+			summary:  []ctxclient.FormattedContext{{Name: "work", ContextId: resumed[0].ContextId, TimeSpent: ctxclient.TimeSpent{Time: 120}}},
+			want:     []float64{90},
+			removed:  30,
+		},
+		{
+			name:     "sub context breaks come off the parent too",
+			contexts: nested,
+			summary: []ctxclient.FormattedContext{{Name: "parent", ContextId: nested[0].ContextId, TimeSpent: ctxclient.TimeSpent{Time: 90},
+				SubContexts: []ctxclient.FormattedContext{{Name: "kid", ContextId: nested[1].ContextId, TimeSpent: ctxclient.TimeSpent{Time: 60}}}}},
+			want:    []float64{65, 45},
+			removed: 25,
+		},
+		{
+			name:     "never below zero",
+			contexts: short,
+			summary:  []ctxclient.FormattedContext{{Name: "short", ContextId: short[0].ContextId, TimeSpent: ctxclient.TimeSpent{Time: 20}}},
+			want:     []float64{0},
+			removed:  30,
+		},
+	}
+	for _, tt := range tests {
+		removed := excludePauses(tt.summary, tt.contexts)
+		got := []float64{}
+		for _, c := range tt.summary {
+			got = append(got, c.TimeSpent.Time)
+			for _, sub := range c.SubContexts {
+				got = append(got, sub.TimeSpent.Time)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+		if removed != tt.removed {
+			t.Errorf("%s: removed %v, want %v", tt.name, removed, tt.removed)
+		}
+	}
+}
+
+func TestActiveSpans(t *testing.T) {
+	start := time.Date(2024, time.March, 13, 9, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	raw := pauseNotes(t,
+		[2]string{"2024-03-13T08:00:00Z", ""},
+		[2]string{"2024-03-13T09:30:00Z", "2024-03-13T09:45:00Z"},
+		[2]string{"2024-03-13T10:30:00Z", ""},
+	)
+	spans := activeSpans(raw, start, end)
+	want := []span{
+		{Start: start, End: start.Add(30 * time.Minute)},
+		{Start: start.Add(45 * time.Minute), End: start.Add(90 * time.Minute)},
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("got %v, want %v", spans, want)
+	}
+	for i := range want {
+		if !spans[i].Start.Equal(want[i].Start) || !spans[i].End.Equal(want[i].End) {
+			t.Errorf("span %d is %v, want %v", i, spans[i], want[i])
+		}
+	}
+	if paused := pausedMinutes(raw, start, end); paused != 45 {
+		t.Errorf("paused %v minutes, want 45", paused)
+	}
+}
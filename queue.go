@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type queueMeta struct {
+	Priority string   `json:"priority,omitempty"`
+	Order    float64  `json:"order,omitempty"`
+	Due      string   `json:"due,omitempty"`
+	Every    string   `json:"every,omitempty"`
+	After    []string `json:"after,omitempty"`
+	Estimate string   `json:"estimate,omitempty"`
+	Under    string   `json:"under,omitempty"`
+	Parent   string   `json:"parent,omitempty"`
+	Snooze   string   `json:"snooze,omitempty"`
+	// how the item left the queue when it wasn't started, see queuehistory.go
+	State string `json:"state,omitempty"`
+}
+
+type queueDisplay struct {
+	ctxclient.Queue
+	queueMeta
+}
+
+var queuePriorities = []string{"high", "normal", "low"}
+
+func queuePriority(priority string) string {
+	priority, err := normalizePriority(priority)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	return priority
+}
+
+func normalizePriority(priority string) (string, error) {
+	if priority == "" {
+		return "normal", nil
+	}
+	priority = strings.ToLower(priority)
+	switch priority {
+	case "h", "hi":
+		priority = "high"
+	case "n", "med", "medium":
+		priority = "normal"
+	case "l", "lo":
+		priority = "low"
+	}
+	if !containsString(queuePriorities, priority) {
+		return "", fmt.Errorf("invalid priority '%s' (%s)", priority, strings.Join(queuePriorities, ", "))
+	}
+	return priority, nil
+}
+
+func priorityRank(priority string) int {
+	for i, p := range queuePriorities {
+		if p == priority {
+			return i
+		}
+	}
+	// items added before priorities existed are normal
+	return 1
+}
+
+func splitQueueMeta(raw json.RawMessage) ([]string, string, error) {
+	notes := []string{}
+	meta := ""
+	entries, err := noteStrings(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, entry := range entries {
+		if parseNote(entry).Type == noteTypeMeta {
+			meta = entry
+		} else {
+			notes = append(notes, entry)
+		}
+	}
+	return notes, meta, nil
+}
+
+func getQueueMeta(q *ctxclient.Queue) queueMeta {
+	_, meta, err := splitQueueMeta(q.Notes)
+	if err != nil || meta == "" {
+		return queueMeta{}
+	}
+	return *parseNote(meta).Meta
+}
+
+func setQueueMeta(q *ctxclient.Queue, meta queueMeta) {
+	notes, _, err := splitQueueMeta(q.Notes)
+	if err != nil {
+		fmt.Printf("Error: queue '%s': %v\n", q.Name, err)
+		os.Exit(exitError)
+	}
+	encoded, err := encodeNote(note{Type: noteTypeMeta, Meta: &meta})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	notesJSON, err := jsonMarshal(append(notes, encoded), false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	q.Notes = notesJSON
+}
+
+func queueNotes(q *ctxclient.Queue) json.RawMessage {
+	notes, _, err := splitQueueMeta(q.Notes)
+	if err != nil {
+		return q.Notes
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+	notesJSON, err := jsonMarshal(notes, false)
+	if err != nil {
+		return q.Notes
+	}
+	return notesJSON
+}
+
+func displayQueue(q ctxclient.Queue) queueDisplay {
+	meta := getQueueMeta(&q)
+	if meta.Priority == "" {
+		meta.Priority = "normal"
+	}
+	// the order only means something relative to other items, the list
+	// order already shows it
+	meta.Order = 0
+	q.Notes = displayNotes(queueNotes(&q))
+	return queueDisplay{Queue: q, queueMeta: meta}
+}
+
+func queueOrder(q *ctxclient.Queue, meta queueMeta) float64 {
+	if meta.Order != 0 {
+		return meta.Order
+	}
+	// never moved, so it stays where it was added
+	created, err := time.Parse(ctxclient.SkDateFormat, q.Created)
+	if err != nil {
+		return 0
+	}
+	return float64(created.Unix())
+}
+
+func sortQueue(qs []ctxclient.Queue) {
+	sort.SliceStable(qs, func(i, j int) bool {
+		mi, mj := getQueueMeta(&qs[i]), getQueueMeta(&qs[j])
+		ri, rj := priorityRank(mi.Priority), priorityRank(mj.Priority)
+		if ri != rj {
+			return ri < rj
+		}
+		return queueOrder(&qs[i], mi) < queueOrder(&qs[j], mj)
+	})
+}
+
+func nextQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, args []string) string {
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(*qs) == 0 {
+		return "queue is empty"
+	}
+	awake, _ := awakeQueue(*qs)
+	ready, _ := unblockedQueue(qClient, ctxClient, awake)
+	if len(ready) == 0 {
+		return "everything in the queue is waiting on something, see ctx q graph"
+	}
+	sortQueue(ready)
+	next := ready[0]
+	output, err := stringifyQueue(&next)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	fmt.Printf("next in queue:\n%s\n", output)
+	if !confirm("start it? [y/N]: ", "n") {
+		return ""
+	}
+	return doQueue(qClient, ctxClient, append([]string{next.Id}, args...))
+}
+
+func moveQueue(qClient *ctxclient.QueueClient, args []string) string {
+	before, args := flagValue(args, "--before")
+	top, args := hasFlag(args, "--top")
+	bottom, args := hasFlag(args, "--bottom")
+	if len(args) == 0 {
+		fmt.Printf("Error: missing queueId\n")
+		os.Exit(exitUsage)
+	}
+	if !top && !bottom && before == "" {
+		fmt.Printf("Error: use --top, --bottom or --before <queueId>\n")
+		os.Exit(exitUsage)
+	}
+	qId := args[0]
+	q, err := qClient.GetQueue(qId)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	sortQueue(*qs)
+	meta := getQueueMeta(q)
+	meta.Priority = queuePriority(meta.Priority)
+	if before != "" {
+		index := -1
+		for i, other := range *qs {
+			if other.Id == before {
+				index = i
+			}
+		}
+		if index == -1 {
+			fmt.Printf("Error: queue '%s' not found in the queue\n", before)
+			os.Exit(exitError)
+		}
+		target := (*qs)[index]
+		targetMeta := getQueueMeta(&target)
+		meta.Priority = queuePriority(targetMeta.Priority)
+		meta.Order = queueOrder(&target, targetMeta) - 1
+		// land between the target and whatever is ahead of it
+		if index > 0 {
+			ahead := (*qs)[index-1]
+			aheadMeta := getQueueMeta(&ahead)
+			if ahead.Id != q.Id && queuePriority(aheadMeta.Priority) == meta.Priority {
+				meta.Order = (queueOrder(&ahead, aheadMeta) + queueOrder(&target, targetMeta)) / 2
+			}
+		}
+	} else {
+		orders := []float64{}
+		for _, other := range *qs {
+			otherMeta := getQueueMeta(&other)
+			if other.Id != q.Id && queuePriority(otherMeta.Priority) == meta.Priority {
+				orders = append(orders, queueOrder(&other, otherMeta))
+			}
+		}
+		if len(orders) == 0 {
+			return fmt.Sprintf("'%s' is the only %s priority item", q.Name, meta.Priority)
+		}
+		sort.Float64s(orders)
+		if top {
+			meta.Order = orders[0] - 1
+		} else {
+			meta.Order = orders[len(orders)-1] + 1
+		}
+	}
+	setQueueMeta(q, meta)
+	_, err = qClient.UpdateQueue(q)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return fmt.Sprintf("moved '%s'", q.Name)
+}
+
+func priorityQueue(qClient *ctxclient.QueueClient, args []string) string {
+	if len(args) < 2 {
+		fmt.Printf("Error: use ctx q priority <queueId> <%s>\n", strings.Join(queuePriorities, "|"))
+		os.Exit(exitUsage)
+	}
+	q, err := qClient.GetQueue(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	meta := getQueueMeta(q)
+	meta.Priority = queuePriority(args[1])
+	setQueueMeta(q, meta)
+	_, err = qClient.UpdateQueue(q)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return fmt.Sprintf("'%s' is now %s priority", q.Name, meta.Priority)
+}
+
+func queueDue(when string) string {
+	due, err := parseDate(when, time.Now())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	return due.Format(dateFormat)
+}
+
+func dueQueue(qClient *ctxclient.QueueClient, args []string) string {
+	if len(args) < 2 {
+		fmt.Printf("Error: use ctx q due <queueId> <date|none>\n")
+		os.Exit(exitUsage)
+	}
+	q, err := qClient.GetQueue(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	meta := getQueueMeta(q)
+	when := strings.Join(args[1:], " ")
+	if when == "none" {
+		meta.Due = ""
+	} else {
+		meta.Due = queueDue(when)
+	}
+	setQueueMeta(q, meta)
+	_, err = qClient.UpdateQueue(q)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if meta.Due == "" {
+		return fmt.Sprintf("'%s' has no due date", q.Name)
+	}
+	return fmt.Sprintf("'%s' is due %s", q.Name, meta.Due)
+}
+
+func dueBetween(qs []ctxclient.Queue, start, end string) []ctxclient.Queue {
+	due := []ctxclient.Queue{}
+	for _, q := range qs {
+		meta := getQueueMeta(&q)
+		if meta.Due == "" || (start != "" && meta.Due < start) || (end != "" && meta.Due > end) {
+			continue
+		}
+		due = append(due, q)
+	}
+	sortQueue(due)
+	sort.SliceStable(due, func(i, j int) bool {
+		return getQueueMeta(&due[i]).Due < getQueueMeta(&due[j]).Due
+	})
+	return due
+}
+
+func overdueQueue(qClient *ctxclient.QueueClient) string {
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	yesterday := time.Now().AddDate(0, 0, -1).Format(dateFormat)
+	overdue := dueBetween(*qs, "", yesterday)
+	if len(overdue) == 0 {
+		return "nothing overdue"
+	}
+	fmt.Println("overdue:")
+	output, err := stringifyQueueList(&overdue)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return output
+}
+
+func upcomingQueue(qClient *ctxclient.QueueClient, args []string) string {
+	days, _ := flagValue(args, "--days", "-d")
+	if days == "" {
+		days = "7"
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 0 {
+		fmt.Printf("Error: invalid number of days '%s'\n", days)
+		os.Exit(exitUsage)
+	}
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	now := time.Now()
+	upcoming := dueBetween(*qs, now.Format(dateFormat), now.AddDate(0, 0, n).Format(dateFormat))
+	if len(upcoming) == 0 {
+		return fmt.Sprintf("nothing due in the next %d days", n)
+	}
+	fmt.Printf("due in the next %d days:\n", n)
+	output, err := stringifyQueueList(&upcoming)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return output
+}
+
+func checkOverdue(qClient *ctxclient.QueueClient) string {
+	if CTX_REPORT_OVERDUE == "false" {
+		return ""
+	}
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		// the reminder shouldn't get in the way of the command
+		return ""
+	}
+	yesterday := time.Now().AddDate(0, 0, -1).Format(dateFormat)
+	overdue := dueBetween(*qs, "", yesterday)
+	switch len(overdue) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("\nqueue item '%s' is overdue (due %s), see ctx q overdue\n", overdue[0].Name, getQueueMeta(&overdue[0]).Due)
+	}
+	return fmt.Sprintf("\n%d queue items are overdue, see ctx q overdue\n", len(overdue))
+}
+
+func queueUnder(ctxClient *ctxclient.ContextClient, contextId string) string {
+	c, err := ctxClient.GetContext(contextId)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if c.ContextId == "" {
+		fmt.Printf("Error: context '%s' not found\n", contextId)
+		os.Exit(exitError)
+	}
+	return c.ContextId
+}
+
+func queueParentContext(qClient *ctxclient.QueueClient, q *ctxclient.Queue, pending map[string]ctxclient.Queue) string {
+	seen := map[string]bool{}
+	for q != nil && !seen[q.Id] {
+		seen[q.Id] = true
+		meta := getQueueMeta(q)
+		if meta.Under != "" {
+			return meta.Under
+		}
+		if meta.Parent == "" {
+			return ""
+		}
+		parent, ok := pending[meta.Parent]
+		if !ok {
+			p, err := qClient.GetQueue(meta.Parent)
+			if err != nil || p.Id == "" {
+				return ""
+			}
+			parent = *p
+		}
+		if parent.ContextId != "" {
+			return parent.ContextId
+		}
+		// a parent that hasn't been started passes on its own parent context
+		q = &parent
+	}
+	return ""
+}
+
+func underContext(qClient *ctxclient.QueueClient, qs []ctxclient.Queue, contextId string) []ctxclient.Queue {
+	pending := pendingQueue(qs)
+	under := []ctxclient.Queue{}
+	for _, q := range qs {
+		if queueParentContext(qClient, &q, pending) == contextId {
+			under = append(under, q)
+		}
+	}
+	return under
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSplitQueueMeta(t *testing.T) {
+	raw := json.RawMessage(`["plain", {"type":"kv","values":{"a":"1"}}, {"type":"meta","meta":{"priority":"high"}}]`)
+	notes, meta, err := splitQueueMeta(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"plain", `{"type":"kv","values":{"a":"1"}}`}
+	if !reflect.DeepEqual(notes, want) {
+		t.Errorf("notes = %q, want %q", notes, want)
+	}
+	if m := parseNote(meta).Meta; m == nil || m.Priority != "high" {
+		t.Errorf("meta = %q, want the high priority meta note", meta)
+	}
+
+	for _, raw := range []string{"", "null"} {
+		notes, meta, err := splitQueueMeta(json.RawMessage(raw))
+		if err != nil || len(notes) != 0 || meta != "" {
+			t.Errorf("splitQueueMeta(%q) = %q %q %v, want nothing", raw, notes, meta, err)
+		}
+	}
+	if _, _, err := splitQueueMeta(json.RawMessage(`{"not":"a list"}`)); err == nil {
+		t.Errorf("splitQueueMeta should fail on notes that aren't a list")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+func queueAfter(qClient *ctxclient.QueueClient, after string) []string {
+	ids := []string{}
+	for _, id := range strings.Split(after, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || containsString(ids, id) {
+			continue
+		}
+		ids = append(ids, queueId(qClient, id))
+	}
+	return ids
+}
+
+func queueId(qClient *ctxclient.QueueClient, id string) string {
+	q, err := qClient.GetQueue(id)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if q.Id == "" {
+		fmt.Printf("Error: queue '%s' not found\n", id)
+		os.Exit(exitError)
+	}
+	return q.Id
+}
+
+func queueBlockers(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, q *ctxclient.Queue, pending map[string]ctxclient.Queue) []ctxclient.Queue {
+	blockers := []ctxclient.Queue{}
+	for _, id := range getQueueMeta(q).After {
+		if dep, ok := pending[id]; ok {
+			blockers = append(blockers, dep)
+			continue
+		}
+		dep, err := qClient.GetQueue(id)
+		// items that can't be found can't block anything
+		if err != nil || dep.Id == "" || dep.Started == "" || dep.ContextId == "" {
+			continue
+		}
+		// a started item is done once its context is
+		c, err := ctxClient.GetContext(dep.ContextId)
+		if err == nil && c.ContextId != "" && c.Completed == "" {
+			blockers = append(blockers, *dep)
+		}
+	}
+	return blockers
+}
+
+func pendingQueue(qs []ctxclient.Queue) map[string]ctxclient.Queue {
+	pending := map[string]ctxclient.Queue{}
+	for _, q := range qs {
+		pending[q.Id] = q
+	}
+	return pending
+}
+
+func unblockedQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, qs []ctxclient.Queue) ([]ctxclient.Queue, []ctxclient.Queue) {
+	pending := pendingQueue(qs)
+	ready, blocked := []ctxclient.Queue{}, []ctxclient.Queue{}
+	for _, q := range qs {
+		if len(queueBlockers(qClient, ctxClient, &q, pending)) > 0 {
+			blocked = append(blocked, q)
+		} else {
+			ready = append(ready, q)
+		}
+	}
+	return ready, blocked
+}
+
+func checkBlockers(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, q *ctxclient.Queue) {
+	if len(getQueueMeta(q).After) == 0 {
+		return
+	}
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	blockers := queueBlockers(qClient, ctxClient, q, pendingQueue(*qs))
+	if len(blockers) == 0 {
+		return
+	}
+	fmt.Printf("'%s' is waiting on:\n", q.Name)
+	for _, b := range blockers {
+		status := "queued"
+		if b.Started != "" {
+			status = fmt.Sprintf("in progress in context %s", b.ContextId)
+		}
+		fmt.Printf("  %s %s (%s)\n", b.Id, b.Name, status)
+	}
+	if !confirm("start it anyway? [y/N]: ", "n") {
+		fmt.Println("cancelled")
+		os.Exit(0)
+	}
+}
+
+func graphQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient) string {
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(*qs) == 0 {
+		return "queue is empty"
+	}
+	sortQueue(*qs)
+	pending := pendingQueue(*qs)
+	children := map[string][]ctxclient.Queue{}
+	roots := []ctxclient.Queue{}
+	for _, q := range *qs {
+		isRoot := true
+		for _, id := range getQueueMeta(&q).After {
+			if _, ok := pending[id]; ok {
+				children[id] = append(children[id], q)
+				isRoot = false
+			}
+		}
+		if isRoot {
+			roots = append(roots, q)
+		}
+	}
+	output := ""
+	for _, q := range roots {
+		output += graphLine(qClient, ctxClient, q, pending, "", "")
+		output += graphChildren(qClient, ctxClient, q.Id, children, pending, "", map[string]bool{q.Id: true})
+	}
+	return strings.TrimSuffix(output, "\n")
+}
+
+func graphChildren(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, id string, children map[string][]ctxclient.Queue, pending map[string]ctxclient.Queue, indent string, seen map[string]bool) string {
+	output := ""
+	kids := children[id]
+	for i, kid := range kids {
+		branch, next := "├─ ", "│  "
+		if i == len(kids)-1 {
+			branch, next = "└─ ", "   "
+		}
+		output += graphLine(qClient, ctxClient, kid, pending, indent, branch)
+		if seen[kid.Id] {
+			continue
+		}
+		seen[kid.Id] = true
+		output += graphChildren(qClient, ctxClient, kid.Id, children, pending, indent+next, seen)
+		delete(seen, kid.Id)
+	}
+	return output
+}
+
+func graphLine(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, q ctxclient.Queue, pending map[string]ctxclient.Queue, indent, branch string) string {
+	status := ""
+	if len(queueBlockers(qClient, ctxClient, &q, pending)) > 0 {
+		status = " [blocked]"
+	}
+	return fmt.Sprintf("%s%s%s %s%s\n", indent, branch, q.Id, q.Name, status)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+func exportQueue(qClient *ctxclient.QueueClient, args []string) string {
+	format, args := flagValue(args, "--format", "-f")
+	out, _ := flagValue(args, "--out", "-o")
+	if format == "" && out == "" {
+		format = "markdown"
+	}
+	format = queueFormat(format, out)
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	sortQueue(*qs)
+	data := ""
+	switch format {
+	case "markdown":
+		data = exportMarkdown(*qs)
+	case "csv":
+		data, err = exportCSV(*qs)
+	default:
+		data = exportTodoTxt(*qs)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if out == "" {
+		fmt.Print(data)
+		return ""
+	}
+	err = os.WriteFile(out, []byte(data), 0644)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return fmt.Sprintf("exported %d items to %s", len(*qs), out)
+}
+
+func taskText(q *ctxclient.Queue) (string, []string) {
+	meta := getQueueMeta(q)
+	words := []string{}
+	switch meta.Priority {
+	case "high":
+		words = append(words, "(A)")
+	case "low":
+		words = append(words, "(C)")
+	}
+	words = append(words, q.Name)
+	if meta.Due != "" {
+		words = append(words, "due:"+meta.Due)
+	}
+	if meta.Snooze != "" {
+		words = append(words, "t:"+meta.Snooze)
+	}
+	if meta.Estimate != "" {
+		words = append(words, "est:"+meta.Estimate)
+	}
+	if meta.Every != "" {
+		words = append(words, "rec:"+strings.ReplaceAll(meta.Every, " ", "_"))
+	}
+	if meta.Under != "" {
+		words = append(words, "under:"+meta.Under)
+	}
+	if len(meta.After) > 0 {
+		words = append(words, "after:"+strings.Join(meta.After, ","))
+	}
+	if meta.Parent != "" {
+		words = append(words, "parent:"+meta.Parent)
+	}
+	if q.Id != "" {
+		words = append(words, "id:"+q.Id)
+	}
+	leftovers := []string{}
+	notes, _ := readNotes(queueNotes(q))
+	for _, n := range notes {
+		if n.Type != noteTypeKV {
+			continue
+		}
+		keys := []string{}
+		for k := range n.Values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := n.Values[k]
+			switch {
+			case k == "project":
+				for _, p := range strings.Fields(v) {
+					words = append(words, "+"+p)
+				}
+			case k == "context":
+				for _, c := range strings.Fields(v) {
+					words = append(words, "@"+c)
+				}
+			case tokenRe.MatchString(k+":"+v) && !strings.HasPrefix(v, "//"):
+				words = append(words, k+":"+v)
+			default:
+				leftovers = append(leftovers, fmt.Sprintf("%s: %s", k, v))
+			}
+		}
+	}
+	return strings.Join(words, " "), leftovers
+}
+
+func exportTodoTxt(qs []ctxclient.Queue) string {
+	output := ""
+	for _, q := range qs {
+		// todo.txt has nowhere to put notes that don't fit as tokens
+		line, _ := taskText(&q)
+		output += line + "\n"
+	}
+	return output
+}
+
+func exportMarkdown(qs []ctxclient.Queue) string {
+	output := ""
+	for _, q := range qs {
+		line, leftovers := taskText(&q)
+		output += fmt.Sprintf("- [ ] %s\n", line)
+		for _, leftover := range leftovers {
+			output += fmt.Sprintf("  - %s\n", leftover)
+		}
+		notes, _ := readNotes(queueNotes(&q))
+		for _, n := range notes {
+			switch n.Type {
+			case noteTypeKV:
+				// already on the task line or in the leftovers
+			case noteTypeLink:
+				title := n.Title
+				if title == "" {
+					title = n.URL
+				}
+				output += fmt.Sprintf("  - [%s](%s)\n", title, n.URL)
+			case noteTypeChecklist:
+				for _, item := range n.Items {
+					box := " "
+					if item.Done {
+						box = "x"
+					}
+					output += fmt.Sprintf("  - [%s] %s\n", box, item.Text)
+				}
+			default:
+				lines := strings.Split(strings.TrimSpace(n.Text), "\n")
+				output += fmt.Sprintf("  - %s\n", lines[0])
+				for _, l := range lines[1:] {
+					output += fmt.Sprintf("    %s\n", l)
+				}
+			}
+		}
+	}
+	return output
+}
+
+func exportCSV(qs []ctxclient.Queue) (string, error) {
+	buffer := &bytes.Buffer{}
+	writer := csv.NewWriter(buffer)
+	writer.Write([]string{"id", "name", "priority", "due", "estimate", "every", "snooze", "under", "after", "parent", "notes"})
+	for _, q := range qs {
+		meta := getQueueMeta(&q)
+		priority := meta.Priority
+		if priority == "" {
+			priority = "normal"
+		}
+		notes := ""
+		if raw := queueNotes(&q); raw != nil {
+			notes = strings.TrimSpace(string(raw))
+		}
+		writer.Write([]string{q.Id, q.Name, priority, meta.Due, meta.Estimate, meta.Every, meta.Snooze, meta.Under, strings.Join(meta.After, ","), meta.Parent, notes})
+	}
+	writer.Flush()
+	return buffer.String(), writer.Error()
+}
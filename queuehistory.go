@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+const (
+	queueStateStarted   = "started"
+	queueStateClosed    = "closed"
+	queueStateCancelled = "cancelled"
+	queueStateArchived  = "archived"
+	queueStateReopened  = "reopened"
+)
+
+// the api only lists items that are still queued, so ctx keeps its own
+// history of items leaving it
+type queueEvent struct {
+	At        string `json:"at"`
+	State     string `json:"state"`
+	QueueId   string `json:"queueId"`
+	Name      string `json:"name"`
+	ContextId string `json:"contextId,omitempty"`
+	// the item a reopened item was copied from
+	From string `json:"from,omitempty"`
+}
+
+func queueHistoryPath() string {
+	return filepath.Join(CTX_STATE_DIR, "queue-history.jsonl")
+}
+
+func recordQueue(event queueEvent) {
+	event.At = time.Now().UTC().Format(ctxclient.SkDateFormat)
+	err := os.MkdirAll(CTX_STATE_DIR, 0755)
+	if err == nil {
+		var f *os.File
+		f, err = os.OpenFile(queueHistoryPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			defer f.Close()
+			var data []byte
+			data, err = jsonMarshal(event, false)
+			if err == nil {
+				_, err = f.Write(data)
+			}
+		}
+	}
+	// history is best effort, it doesn't stop the command
+	if err != nil {
+		fmt.Printf("couldn't record queue history: %v\n", err)
+	}
+}
+
+func readQueueHistory() ([]queueEvent, error) {
+	events := []queueEvent{}
+	f, err := os.Open(queueHistoryPath())
+	if os.IsNotExist(err) {
+		return events, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		event := queueEvent{}
+		if line == "" || json.Unmarshal([]byte(line), &event) != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+func historyQueue(args []string) string {
+	since, _ := flagValue(args, "--since")
+	if since == "" {
+		since = "1w"
+	}
+	_, _, cutoff, err := parseSince(since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	events, err := readQueueHistory()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	recent := []queueEvent{}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].At >= cutoff.Format(ctxclient.SkDateFormat) {
+			recent = append(recent, events[i])
+		}
+	}
+	if len(recent) == 0 {
+		return fmt.Sprintf("no queue history in the last %s", since)
+	}
+	output, err := stringifyQueueEvents(&recent)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	return output
+}
+
+func stringifyQueueEvents(e *[]queueEvent) (string, error) {
+	eJson, err := jsonMarshalIndent(e, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return "", err
+	}
+	if EXPORT_TYPE == "yaml" {
+		return printYaml(eJson)
+	}
+	return string(eJson), nil
+}
+
+func finishQueue(qClient *ctxclient.QueueClient, args []string, state string) string {
+	if len(args) == 0 {
+		fmt.Printf("Error: missing queueId\n")
+		os.Exit(exitUsage)
+	}
+	qId := args[0]
+	q, err := qClient.GetQueue(qId)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if q.Started != "" {
+		return fmt.Sprintf("queue '%s' isn't in the queue anymore", q.Name)
+	}
+	meta := getQueueMeta(q)
+	meta.State = state
+	setQueueMeta(q, meta)
+	_, err = qClient.UpdateQueue(q)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	_, err = qClient.StartQueue(qId, "")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	recordQueue(queueEvent{State: state, QueueId: qId, Name: q.Name})
+	output := fmt.Sprintf("queue %s %s", qId, state)
+	// archiving stops a recurring item, closing or cancelling one occurrence
+	// doesn't
+	if state != queueStateArchived {
+		output += repeatQueue(qClient, q)
+	}
+	return output
+}
+
+func reopenQueue(qClient *ctxclient.QueueClient, args []string) string {
+	if len(args) == 0 {
+		fmt.Printf("Error: missing queueId\n")
+		os.Exit(exitUsage)
+	}
+	q, err := qClient.GetQueue(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if q.Started == "" {
+		return fmt.Sprintf("queue '%s' is already in the queue", q.Name)
+	}
+	// the api can't undo starting an item, so it's added again as a copy
+	meta := getQueueMeta(q)
+	meta.State = ""
+	meta.Order = 0
+	meta.Snooze = ""
+	// closing it already queued the next occurrence
+	meta.Every = ""
+	reopened := ctxclient.Queue{
+		Name:  q.Name,
+		Notes: q.Notes,
+	}
+	setQueueMeta(&reopened, meta)
+	newQueueId, err := qClient.UpdateQueue(&reopened)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	recordQueue(queueEvent{State: queueStateReopened, QueueId: newQueueId, Name: q.Name, From: q.Id})
+	return fmt.Sprintf("reopened '%s'\nwith id: %s\n", q.Name, newQueueId)
+}
+
+func snoozeQueue(qClient *ctxclient.QueueClient, args []string) string {
+	until, args := flagValue(args, "--until", "-u")
+	if len(args) == 0 {
+		fmt.Printf("Error: missing queueId\n")
+		os.Exit(exitUsage)
+	}
+	if until == "" {
+		until = "tomorrow"
+	}
+	q, err := qClient.GetQueue(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	meta := getQueueMeta(q)
+	meta.Snooze = queueDue(until)
+	if meta.Snooze <= time.Now().Format(dateFormat) {
+		meta.Snooze = ""
+	}
+	setQueueMeta(q, meta)
+	_, err = qClient.UpdateQueue(q)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if meta.Snooze == "" {
+		return fmt.Sprintf("'%s' is back in the queue", q.Name)
+	}
+	return fmt.Sprintf("'%s' is snoozed until %s", q.Name, meta.Snooze)
+}
+
+func isSnoozed(q *ctxclient.Queue) bool {
+	snooze := getQueueMeta(q).Snooze
+	return snooze != "" && snooze > time.Now().Format(dateFormat)
+}
+
+func awakeQueue(qs []ctxclient.Queue) ([]ctxclient.Queue, []ctxclient.Queue) {
+	awake, snoozed := []ctxclient.Queue{}, []ctxclient.Queue{}
+	for _, q := range qs {
+		if isSnoozed(&q) {
+			snoozed = append(snoozed, q)
+		} else {
+			awake = append(awake, q)
+		}
+	}
+	return awake, snoozed
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type taskItem struct {
+	Line int
+	// the item's id in the file, which after and parent refer to
+	Id    string
+	Name  string
+	Notes []note
+	Meta  queueMeta
+}
+
+var (
+	queueFormats = []string{"markdown", "todotxt", "csv"}
+	// todo.txt priorities past C are all low
+	todoPriorities = map[string]string{"A": "high", "B": "normal", "C": "low"}
+	mdTaskRe       = regexp.MustCompile(`^(\s*)[-*+]\s+\[([ xX])\]\s+(.*)$`)
+	mdBulletRe     = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdLinkRe       = regexp.MustCompile(`^\[([^\]]*)\]\((\S+)\)$`)
+	todoPriorityRe = regexp.MustCompile(`^\(([A-Z])\)$`)
+	tokenRe        = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_-]*):(\S+)$`)
+)
+
+func queueFormat(format, path string) string {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".markdown":
+			format = "markdown"
+		case ".csv":
+			format = "csv"
+		default:
+			format = "todotxt"
+		}
+	}
+	switch strings.ToLower(format) {
+	case "md", "markdown":
+		return "markdown"
+	case "todo", "todotxt", "todo.txt", "txt":
+		return "todotxt"
+	case "csv":
+		return "csv"
+	}
+	fmt.Printf("Error: unknown format '%s' (%s)\n", format, strings.Join(queueFormats, ", "))
+	os.Exit(exitUsage)
+	return ""
+}
+
+func parseTaskText(text string) (taskItem, error) {
+	item := taskItem{}
+	meta := &item.Meta
+	words := strings.Fields(text)
+	if len(words) > 0 {
+		if match := todoPriorityRe.FindStringSubmatch(words[0]); match != nil {
+			meta.Priority = todoPriorities[match[1]]
+			if meta.Priority == "" {
+				meta.Priority = "low"
+			}
+			words = words[1:]
+		}
+	}
+	// the creation date can't be kept, the api sets it
+	if len(words) > 0 {
+		if _, err := time.Parse(dateFormat, words[0]); err == nil {
+			words = words[1:]
+		}
+	}
+	name := []string{}
+	projects, contexts := []string{}, []string{}
+	values := map[string]string{}
+	for _, word := range words {
+		if len(word) > 1 && strings.HasPrefix(word, "+") {
+			projects = append(projects, word[1:])
+			continue
+		}
+		if len(word) > 1 && strings.HasPrefix(word, "@") {
+			contexts = append(contexts, word[1:])
+			continue
+		}
+		match := tokenRe.FindStringSubmatch(word)
+		// urls have a colon too
+		if match == nil || strings.HasPrefix(match[2], "//") {
+			name = append(name, word)
+			continue
+		}
+		key, value := strings.ToLower(match[1]), match[2]
+		var err error
+		switch key {
+		case "due":
+			meta.Due, err = taskDate(value)
+		case "t":
+			meta.Snooze, err = taskDate(value)
+		case "est", "estimate":
+			if d, perr := time.ParseDuration(value); perr != nil || d <= 0 {
+				err = fmt.Errorf("invalid estimate '%s'", value)
+			}
+			meta.Estimate = value
+		case "rec", "every":
+			// rules with spaces, like cron, are written with underscores
+			meta.Every = strings.ReplaceAll(strings.TrimPrefix(value, "+"), "_", " ")
+			_, err = parseRecurrence(meta.Every)
+		case "pri", "priority":
+			meta.Priority, err = normalizePriority(value)
+		case "under":
+			meta.Under = value
+		case "after":
+			meta.After = splitIds(value)
+		case "parent":
+			meta.Parent = value
+		case "id":
+			item.Id = value
+		default:
+			values[match[1]] = value
+		}
+		if err != nil {
+			return item, err
+		}
+	}
+	if len(projects) > 0 {
+		values["project"] = strings.Join(projects, " ")
+	}
+	if len(contexts) > 0 {
+		values["context"] = strings.Join(contexts, " ")
+	}
+	item.Notes = []note{}
+	if len(values) > 0 {
+		item.Notes = append(item.Notes, note{Type: noteTypeKV, Values: values})
+	}
+	if len(name) == 0 {
+		return item, fmt.Errorf("task has no name")
+	}
+	item.Name = strings.Join(name, " ")
+	return item, nil
+}
+
+func splitIds(value string) []string {
+	ids := []string{}
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func taskDate(value string) (string, error) {
+	t, err := parseDate(value, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return t.Format(dateFormat), nil
+}
+
+func parseTodoTxt(data string) ([]taskItem, int, error) {
+	items := []taskItem{}
+	done := 0
+	for i, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "x ") {
+			done++
+			continue
+		}
+		item, err := parseTaskText(line)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		item.Line = i + 1
+		items = append(items, item)
+	}
+	return items, done, nil
+}
+
+func parseMarkdown(data string) ([]taskItem, int, error) {
+	items := []taskItem{}
+	done := 0
+	var current *taskItem
+	taskIndent := 0
+	// whether the last note can be carried on by the next line
+	continuing := false
+	for i, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continuing = false
+			continue
+		}
+		indent := len(strings.ReplaceAll(line, "\t", "    ")) - len(strings.TrimLeft(strings.ReplaceAll(line, "\t", "    "), " "))
+		if match := mdTaskRe.FindStringSubmatch(line); match != nil && (current == nil || indent <= taskIndent) {
+			current = nil
+			continuing = false
+			taskIndent = indent
+			if match[2] != " " {
+				done++
+				// keep the checked task around so its sub bullets are skipped
+				current = &taskItem{Line: -1}
+				continue
+			}
+			item, err := parseTaskText(match[3])
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			item.Line = i + 1
+			items = append(items, item)
+			current = &items[len(items)-1]
+			continue
+		}
+		if current == nil || indent <= taskIndent {
+			// headings and other text end the task above them
+			current = nil
+			continue
+		}
+		if current.Line == -1 {
+			continue
+		}
+		if match := mdTaskRe.FindStringSubmatch(line); match != nil {
+			item := checklistItem{Text: strings.TrimSpace(match[3]), Done: match[2] != " "}
+			last := len(current.Notes) - 1
+			if last >= 0 && current.Notes[last].Type == noteTypeChecklist {
+				current.Notes[last].Items = append(current.Notes[last].Items, item)
+			} else {
+				current.Notes = append(current.Notes, note{Type: noteTypeChecklist, Items: []checklistItem{item}})
+			}
+			continuing = false
+			continue
+		}
+		if match := mdBulletRe.FindStringSubmatch(line); match != nil {
+			text := strings.TrimSpace(match[2])
+			if link := mdLinkRe.FindStringSubmatch(text); link != nil {
+				title := link[1]
+				// ctx q export writes links without a title as [url](url)
+				if title == link[2] {
+					title = ""
+				}
+				current.Notes = append(current.Notes, note{Type: noteTypeLink, Title: title, URL: link[2]})
+				continuing = false
+			} else {
+				current.Notes = append(current.Notes, note{Type: noteTypeText, Text: text})
+				continuing = true
+			}
+			continue
+		}
+		last := len(current.Notes) - 1
+		if continuing && last >= 0 {
+			current.Notes[last].Text += "\n" + strings.TrimSpace(line)
+		} else {
+			current.Notes = append(current.Notes, note{Type: noteTypeText, Text: strings.TrimSpace(line)})
+			continuing = true
+		}
+	}
+	return items, done, nil
+}
+
+func parseQueueCSV(data string) ([]taskItem, int, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []taskItem{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, 0, fmt.Errorf("csv needs a name column")
+	}
+	items := []taskItem{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		item := taskItem{Line: line, Id: field("id"), Name: field("name")}
+		if item.Name == "" {
+			continue
+		}
+		item.Meta.Priority, err = normalizePriority(field("priority"))
+		if err == nil && field("due") != "" {
+			item.Meta.Due, err = taskDate(field("due"))
+		}
+		if err == nil && field("snooze") != "" {
+			item.Meta.Snooze, err = taskDate(field("snooze"))
+		}
+		if err == nil && field("estimate") != "" {
+			item.Meta.Estimate = field("estimate")
+			if d, perr := time.ParseDuration(item.Meta.Estimate); perr != nil || d <= 0 {
+				err = fmt.Errorf("invalid estimate '%s'", item.Meta.Estimate)
+			}
+		}
+		if err == nil && field("every") != "" {
+			item.Meta.Every = field("every")
+			_, err = parseRecurrence(item.Meta.Every)
+		}
+		item.Meta.Under = field("under")
+		item.Meta.After = splitIds(field("after"))
+		item.Meta.Parent = field("parent")
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		// a JSON list as written by ctx q export, or one note per line
+		item.Notes = csvNotes(field("notes"))
+		items = append(items, item)
+	}
+	return items, 0, nil
+}
+
+func csvNotes(field string) []note {
+	notes := []note{}
+	if field == "" {
+		return notes
+	}
+	encoded := []string{}
+	if strings.HasPrefix(field, "[") && json.Unmarshal([]byte(field), &encoded) == nil {
+		for _, s := range encoded {
+			notes = append(notes, parseNote(s))
+		}
+		return notes
+	}
+	for _, line := range strings.Split(field, "\n") {
+		if strings.TrimSpace(line) != "" {
+			notes = append(notes, note{Type: noteTypeText, Text: strings.TrimSpace(line)})
+		}
+	}
+	return notes
+}
+
+func importQueue(qClient *ctxclient.QueueClient, ctxClient *ctxclient.ContextClient, args []string) string {
+	format, args := flagValue(args, "--format", "-f")
+	if len(args) == 0 {
+		fmt.Printf("Error: missing file to import\n")
+		os.Exit(exitUsage)
+	}
+	path := args[0]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	var items []taskItem
+	var done int
+	switch queueFormat(format, path) {
+	case "markdown":
+		items, done, err = parseMarkdown(string(data))
+	case "csv":
+		items, done, err = parseQueueCSV(string(data))
+	default:
+		items, done, err = parseTodoTxt(string(data))
+	}
+	if err != nil {
+		fmt.Printf("Error: %s %v\n", path, err)
+		os.Exit(exitError)
+	}
+	if done > 0 {
+		fmt.Printf("skipping %d completed tasks\n", done)
+	}
+	if len(items) == 0 {
+		return fmt.Sprintf("no tasks found in %s", path)
+	}
+	inFile := map[string]bool{}
+	for _, item := range items {
+		if item.Id != "" {
+			inFile[item.Id] = true
+		}
+	}
+	checkedUnder, checkedRefs := map[string]bool{}, map[string]bool{}
+	for _, item := range items {
+		if under := item.Meta.Under; under != "" && !checkedUnder[under] {
+			c, err := ctxClient.GetContext(under)
+			if err != nil {
+				fmt.Printf("Error: line %d: %v\n", item.Line, err)
+				os.Exit(exitError)
+			}
+			if c.ContextId == "" {
+				fmt.Printf("Error: line %d: context '%s' not found\n", item.Line, under)
+				os.Exit(exitError)
+			}
+			checkedUnder[under] = true
+		}
+		for _, ref := range append([]string{item.Meta.Parent}, item.Meta.After...) {
+			if ref == "" || inFile[ref] || checkedRefs[ref] {
+				continue
+			}
+			q, err := qClient.GetQueue(ref)
+			if err != nil {
+				fmt.Printf("Error: line %d: %v\n", item.Line, err)
+				os.Exit(exitError)
+			}
+			if q.Id == "" {
+				fmt.Printf("Error: line %d: queue item '%s' not found\n", item.Line, ref)
+				os.Exit(exitError)
+			}
+			checkedRefs[ref] = true
+		}
+	}
+
+	qs, err := qClient.ListQueue()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	queued := map[string]string{}
+	for _, q := range *qs {
+		queued[strings.ToLower(strings.TrimSpace(q.Name))] = q.Id
+	}
+	// ids in the file point at the items they're imported as
+	ids := map[string]string{}
+	fresh, duplicates := []taskItem{}, []taskItem{}
+	for _, item := range items {
+		key := strings.ToLower(item.Name)
+		if id, ok := queued[key]; ok {
+			if id == "" {
+				fmt.Printf("duplicate: '%s' (line %d) is in the file more than once\n", item.Name, item.Line)
+			} else {
+				fmt.Printf("duplicate: '%s' (line %d) is already queued as %s\n", item.Name, item.Line, id)
+				if item.Id != "" {
+					ids[item.Id] = id
+				}
+			}
+			duplicates = append(duplicates, item)
+			continue
+		}
+		queued[key] = ""
+		fresh = append(fresh, item)
+	}
+	if len(fresh) > 0 && !confirm(fmt.Sprintf("import %d items? [Y/n]: ", len(fresh)), "y") {
+		fresh = []taskItem{}
+	}
+	if len(duplicates) > 0 && confirm(fmt.Sprintf("import the %d duplicates too? [y/N]: ", len(duplicates)), "n") {
+		fresh = append(fresh, duplicates...)
+		sort.SliceStable(fresh, func(i, j int) bool {
+			return fresh[i].Line < fresh[j].Line
+		})
+	}
+	if len(fresh) == 0 {
+		return "cancelled"
+	}
+	// keep the file's order after whatever is already queued
+	order := float64(time.Now().Unix())
+	output := ""
+	linked := []taskItem{}
+	for i, item := range fresh {
+		q := ctxclient.Queue{Name: item.Name}
+		notes := []string{}
+		for _, n := range item.Notes {
+			encoded, err := encodeNote(n)
+			if err != nil {
+				fmt.Printf("Error: line %d: %v\n", item.Line, err)
+				os.Exit(exitError)
+			}
+			notes = append(notes, encoded)
+		}
+		setQueueNotes(&q, stampNotes(notes, noteSourceImport, ""))
+		item.Meta.Priority = queuePriority(item.Meta.Priority)
+		item.Meta.Order = order + float64(i)/1000
+		setFirstDue(&item.Meta)
+		meta := item.Meta
+		// items can point at ones further down the file, so these are set
+		// once everything has been added
+		meta.After, meta.Parent = nil, ""
+		setQueueMeta(&q, meta)
+		newQueueId, err := qClient.UpdateQueue(&q)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		if item.Id != "" {
+			ids[item.Id] = newQueueId
+		}
+		if len(item.Meta.After) > 0 || item.Meta.Parent != "" {
+			item.Id = newQueueId
+			linked = append(linked, item)
+		}
+		output += fmt.Sprintf("%s %s\n", newQueueId, item.Name)
+	}
+	for _, item := range linked {
+		q, err := qClient.GetQueue(item.Id)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		meta := getQueueMeta(q)
+		meta.After = importedIds(item.Meta.After, ids, inFile)
+		if parent := importedIds([]string{item.Meta.Parent}, ids, inFile); len(parent) > 0 {
+			meta.Parent = parent[0]
+		}
+		setQueueMeta(q, meta)
+		_, err = qClient.UpdateQueue(q)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	return fmt.Sprintf("imported %d items:\n%s", len(fresh), output)
+}
+
+func importedIds(refs []string, ids map[string]string, inFile map[string]bool) []string {
+	mapped := []string{}
+	// anything not in the file is already queued, items in the file that
+	// weren't imported are dropped
+	for _, ref := range refs {
+		if id, ok := ids[ref]; ok {
+			mapped = append(mapped, id)
+		} else if ref != "" && !inFile[ref] {
+			mapped = append(mapped, ref)
+		}
+	}
+	return mapped
+}
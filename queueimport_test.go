@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+func testQueue(t *testing.T, id, name string, meta queueMeta, notes ...note) ctxclient.Queue {
+	q := ctxclient.Queue{Id: id, Name: name}
+	encoded := []string{}
+	for _, n := range notes {
+		s, err := encodeNote(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded = append(encoded, s)
+	}
+	setQueueNotes(&q, encoded)
+	setQueueMeta(&q, meta)
+	return q
+}
+
+func TestQueueExportImport(t *testing.T) {
+	qs := []ctxclient.Queue{
+		testQueue(t, "q1", "write the plan", queueMeta{Priority: "high", Due: "2030-01-02", Estimate: "2h", Every: "0 9 * * 1"},
+			note{Type: noteTypeText, Text: "start with the api"},
+			note{Type: noteTypeKV, Values: map[string]string{"project": "ctx"}}),
+		testQueue(t, "q2", "review it", queueMeta{Priority: "normal", After: []string{"q1", "q9"}, Under: "c1"}),
+		testQueue(t, "q3", "ship it", queueMeta{Priority: "low", Parent: "q2", Snooze: "2030-02-03"},
+			note{Type: noteTypeLink, Title: "docs", URL: "https://example.com/docs"}),
+	}
+	csvData, err := exportCSV(qs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data string
+		// todo.txt has nowhere to put notes
+		notes bool
+		parse func(string) ([]taskItem, int, error)
+	}{
+		{exportTodoTxt(qs), false, parseTodoTxt},
+		{exportMarkdown(qs), true, parseMarkdown},
+		{csvData, true, parseQueueCSV},
+	}
+	for _, tt := range tests {
+		data := tt.data
+		items, _, err := tt.parse(data)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, data)
+		}
+		if len(items) != len(qs) {
+			t.Fatalf("got %d items, want %d\n%s", len(items), len(qs), data)
+		}
+		for i, item := range items {
+			want := getQueueMeta(&qs[i])
+			if item.Id != qs[i].Id || item.Name != qs[i].Name {
+				t.Errorf("item %d is %s '%s', want %s '%s'\n%s", i, item.Id, item.Name, qs[i].Id, qs[i].Name, data)
+			}
+			got := item.Meta
+			if got.Priority == "" {
+				got.Priority = "normal"
+			}
+			if len(got.After) == 0 {
+				got.After = nil
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("item %d meta is %+v, want %+v\n%s", i, got, want, data)
+			}
+		}
+		if tt.notes && (len(items[0].Notes) != 2 || len(items[2].Notes) != 1 || items[2].Notes[0].URL != "https://example.com/docs") {
+			t.Errorf("notes are %+v and %+v\n%s", items[0].Notes, items[2].Notes, data)
+		}
+	}
+}
+
+func TestImportedIds(t *testing.T) {
+	ids := map[string]string{"a": "q10", "b": "q11"}
+	inFile := map[string]bool{"a": true, "b": true, "c": true}
+	got := importedIds([]string{"a", "c", "q5", "b", ""}, ids, inFile)
+	want := []string{"q10", "q5", "q11"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("importedIds = %v, want %v", got, want)
+	}
+}
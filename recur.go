@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+// queue items are tracked by the day, so a cron rule's minute and hour are
+// checked but otherwise ignored
+type recurrence struct {
+	n        int
+	unit     string
+	weekdays map[time.Weekday]bool
+	cron     *cronSpec
+}
+
+type cronSpec struct {
+	dom, month, dow  map[int]bool
+	domStar, dowStar bool
+}
+
+func parseRecurrence(rule string) (*recurrence, error) {
+	rule = strings.TrimSpace(rule)
+	if fields := strings.Fields(rule); len(fields) == 5 {
+		cron, err := parseCron(fields)
+		if err != nil {
+			return nil, err
+		}
+		return &recurrence{cron: cron}, nil
+	}
+	lower := strings.ToLower(rule)
+	if strings.HasPrefix(lower, "every ") {
+		rule = strings.TrimSpace(rule[len("every "):])
+		lower = strings.ToLower(rule)
+	}
+	if strings.HasPrefix(lower, "other ") {
+		rule = "2 " + strings.TrimSpace(rule[len("other "):])
+		lower = strings.ToLower(rule)
+	}
+	switch lower {
+	case "biweekly", "fortnightly", "fortnight":
+		return &recurrence{n: 2, unit: "w"}, nil
+	case "bimonthly":
+		return &recurrence{n: 2, unit: "M"}, nil
+	case "day", "daily":
+		return &recurrence{n: 1, unit: "d"}, nil
+	case "week", "weekly":
+		return &recurrence{n: 1, unit: "w"}, nil
+	case "month", "monthly":
+		return &recurrence{n: 1, unit: "M"}, nil
+	case "year", "yearly":
+		return &recurrence{n: 1, unit: "y"}, nil
+	case "weekday", "weekdays":
+		lower = "mon,tue,wed,thu,fri"
+	}
+	if n, unit, ok := parseCount(rule); ok && n > 0 {
+		return &recurrence{n: n, unit: unit}, nil
+	}
+	days := map[time.Weekday]bool{}
+	for _, name := range strings.Split(lower, ",") {
+		day, ok := weekdays[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("can't read '%s', try something like monday, mon,thu, every 2 weeks, biweekly, monthly or a cron expression like '0 9 * * 1'", rule)
+		}
+		days[day] = true
+	}
+	return &recurrence{weekdays: days}, nil
+}
+
+func (r *recurrence) isInterval() bool {
+	return r.unit != ""
+}
+
+func (r *recurrence) next(t time.Time) time.Time {
+	t = startOfDay(t)
+	if r.isInterval() {
+		return addUnits(t, r.n, r.unit)
+	}
+	// a rule that can happen lands at least once in 4 years (feb 29 being
+	// the worst case)
+	for day := t.AddDate(0, 0, 1); day.Before(t.AddDate(4, 0, 1)); day = day.AddDate(0, 0, 1) {
+		if r.matches(day) {
+			return day
+		}
+	}
+	return time.Time{}
+}
+
+func (r *recurrence) matches(day time.Time) bool {
+	if r.weekdays != nil {
+		return r.weekdays[day.Weekday()]
+	}
+	c := r.cron
+	if !c.month[int(day.Month())] {
+		return false
+	}
+	dom, dow := c.dom[day.Day()], c.dow[int(day.Weekday())]
+	// like cron, when both are restricted either one matching is enough
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+var cronNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+func parseCron(fields []string) (*cronSpec, error) {
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := []map[int]bool{}
+	for i, field := range fields {
+		set, err := parseCronField(strings.ToLower(field), bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field '%s': %v", field, err)
+		}
+		sets = append(sets, set)
+	}
+	// 7 is also sunday
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	c := &cronSpec{
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	if c.dowStar && !c.domStar && !cronDaysExist(c) {
+		return nil, fmt.Errorf("'%s' never happens, none of those days are in those months", strings.Join(fields, " "))
+	}
+	return c, nil
+}
+
+func cronDaysExist(c *cronSpec) bool {
+	for month := range c.month {
+		// leap years give feb a 29th
+		last := time.Date(2024, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		for day := range c.dom {
+			if day <= last {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return nil, fmt.Errorf("bad step '%s'", part[i+1:])
+			}
+			step = s
+			part = part[:i]
+		}
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = cronValue(bounds[0])
+			if err != nil {
+				return nil, err
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = cronValue(bounds[1])
+				if err != nil {
+					return nil, err
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("out of range %d-%d", min, max)
+		}
+		for v := start; v <= end; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func cronValue(s string) (int, error) {
+	if v, ok := cronNames[s]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad value '%s'", s)
+	}
+	return v, nil
+}
+
+func queueEvery(rule string) *recurrence {
+	r, err := parseRecurrence(rule)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	return r
+}
+
+func setFirstDue(meta *queueMeta) {
+	if meta.Every == "" || meta.Due != "" {
+		return
+	}
+	r, err := parseRecurrence(meta.Every)
+	if err != nil || r.isInterval() {
+		return
+	}
+	if due := r.next(time.Now().AddDate(0, 0, -1)); !due.IsZero() {
+		meta.Due = due.Format(dateFormat)
+	}
+}
+
+func repeatQueue(qClient *ctxclient.QueueClient, q *ctxclient.Queue) string {
+	meta := getQueueMeta(q)
+	if meta.Every == "" {
+		return ""
+	}
+	r, err := parseRecurrence(meta.Every)
+	if err != nil {
+		return fmt.Sprintf("\ncouldn't repeat '%s': %v\n", q.Name, err)
+	}
+	today := startOfDay(time.Now())
+	base := today
+	if meta.Due != "" {
+		due, err := time.ParseInLocation(dateFormat, meta.Due, today.Location())
+		if err == nil {
+			base = due
+		}
+	}
+	due := r.next(base)
+	for !due.IsZero() && due.Before(today) {
+		due = r.next(due)
+	}
+	if due.IsZero() {
+		return fmt.Sprintf("\ncouldn't repeat '%s': '%s' never comes round again\n", q.Name, meta.Every)
+	}
+	next := ctxclient.Queue{
+		Name:  q.Name,
+		Notes: queueNotes(q),
+	}
+	setQueueMeta(&next, queueMeta{
+		Priority: meta.Priority,
+		Due:      due.Format(dateFormat),
+		Every:    meta.Every,
+		Estimate: meta.Estimate,
+		Under:    meta.Under,
+		Parent:   meta.Parent,
+		After:    meta.After,
+	})
+	nextId, err := qClient.UpdateQueue(&next)
+	if err != nil {
+		return fmt.Sprintf("\ncouldn't repeat '%s': %v\n", q.Name, err)
+	}
+	return fmt.Sprintf("\nnext '%s' is due %s\nwith id: %s\n", next.Name, due.Format(dateFormat), nextId)
+}
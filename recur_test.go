@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	tests := []struct {
+		rule string
+		from string
+		want string
+	}{
+		// 2024-03-13 is a wednesday
+		{"monday", "2024-03-13", "2024-03-18"},
+		{"wed", "2024-03-13", "2024-03-20"},
+		{"mon,thu", "2024-03-13", "2024-03-14"},
+		{"tue, fri", "2024-03-15", "2024-03-19"},
+		{"weekdays", "2024-03-15", "2024-03-18"},
+		{"daily", "2024-03-13", "2024-03-14"},
+		{"3 days", "2024-03-13", "2024-03-16"},
+		{"every 2 weeks", "2024-03-13", "2024-03-27"},
+		{"biweekly", "2024-03-13", "2024-03-27"},
+		{"every other day", "2024-03-13", "2024-03-15"},
+		{"monthly", "2024-01-31", "2024-03-02"},
+		{"bimonthly", "2024-03-13", "2024-05-13"},
+		{"yearly", "2024-03-13", "2025-03-13"},
+		{"0 9 * * 1", "2024-03-13", "2024-03-18"},
+		{"0 9 * * 7", "2024-03-13", "2024-03-17"},
+		{"0 9 * * mon-wed", "2024-03-13", "2024-03-18"},
+		{"0 9 * * 1-5/2", "2024-03-13", "2024-03-15"},
+		{"0 9 1,15 * *", "2024-03-13", "2024-03-15"},
+		{"0 9 */10 * *", "2024-03-13", "2024-03-21"},
+		{"0 9 5/10 * *", "2024-03-13", "2024-03-15"},
+		{"0 9 1 jan,jul *", "2024-03-13", "2024-07-01"},
+		{"*/15 */2 31 * *", "2024-03-31", "2024-05-31"},
+		// day of month and day of week both set, either one is enough
+		{"0 9 20 * fri", "2024-03-13", "2024-03-15"},
+		{"0 9 20 * fri", "2024-03-16", "2024-03-20"},
+		{"0 9 31 2 mon", "2024-03-13", "2025-02-03"},
+		// a stepped * still counts as unrestricted, so both have to match
+		{"0 9 */10 * mon", "2024-03-13", "2024-04-01"},
+		{"0 9 29 2 *", "2024-03-13", "2028-02-29"},
+		{"0 9 29 2 *", "2024-02-28", "2024-02-29"},
+	}
+	for _, tt := range tests {
+		r, err := parseRecurrence(tt.rule)
+		if err != nil {
+			t.Errorf("parseRecurrence(%q): %v", tt.rule, err)
+			continue
+		}
+		from, _ := time.ParseInLocation(dateFormat, tt.from, time.Local)
+		if got := r.next(from).Format(dateFormat); got != tt.want {
+			t.Errorf("next(%q, %s) = %s, want %s", tt.rule, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseRecurrenceInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"someday",
+		"mon,someday",
+		"0 days",
+		"0 9 30 2 *",
+		"0 9 31 4,6,9,11 *",
+		"0 9 30-31 feb *",
+		"0 9 32 * *",
+		"0 9 * 13 *",
+		"0 9 * * 8",
+		"0 24 * * *",
+		"0 9 */0 * *",
+		"0 9 5-1 * *",
+		"0 9 * * funday",
+	} {
+		if _, err := parseRecurrence(rule); err == nil {
+			t.Errorf("parseRecurrence(%q) should fail", rule)
+		}
+	}
+}
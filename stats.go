@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type statsDay struct {
+	Date     string  `json:"date"`
+	Minutes  float64 `json:"minutes"`
+	Tracked  string  `json:"tracked"`
+	Switches int     `json:"switches"`
+}
+
+type statsContext struct {
+	Name     string  `json:"name"`
+	Minutes  float64 `json:"minutes"`
+	Tracked  string  `json:"tracked"`
+	Contexts int     `json:"contexts"`
+}
+
+type statsStreak struct {
+	Name    string `json:"name"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Length  string `json:"length"`
+	minutes float64
+}
+
+type statsReport struct {
+	Since         string         `json:"since"`
+	Tracked       string         `json:"tracked"`
+	Contexts      int            `json:"contexts"`
+	AverageLength string         `json:"averageLength"`
+	Switches      int            `json:"switches"`
+	Days          []statsDay     `json:"days"`
+	TopContexts   []statsContext `json:"topContexts"`
+	LongestStreak *statsStreak   `json:"longestStreak,omitempty"`
+	// minutes tracked by weekday (sunday first) and hour, local time
+	Hours [7][24]float64 `json:"hours"`
+}
+
+func statsCmd(ctxClient *ctxclient.ContextClient, args []string) string {
+	since, args := flagValue(args, "--since")
+	asJson, args := hasFlag(args, "--json")
+	top, _ := flagValue(args, "--top")
+	if since == "" {
+		since = "30d"
+	}
+	topCount := 5
+	if top != "" {
+		_, err := fmt.Sscan(top, &topCount)
+		if err != nil || topCount < 1 {
+			fmt.Printf("Error: invalid --top '%s'\n", top)
+			os.Exit(exitUsage)
+		}
+	}
+	start, unit, cutoff, err := parseSince(since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	cs, err := ctxClient.ListContexts(ctxclient.QSParams{
+		"start": start,
+		"end":   "0",
+		"unit":  unit,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(*cs) == 0 {
+		return fmt.Sprintf("no contexts in the last %s", since)
+	}
+	report := contextStats(ctxClient, *cs, cutoff.Local(), topCount)
+	report.Since = since
+	if asJson {
+		output, err := stringifyStats(&report)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		return output
+	}
+	return renderStats(report)
+}
+
+func contextStats(ctxClient *ctxclient.ContextClient, contexts []ctxclient.Context, cutoff time.Time, topCount int) statsReport {
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].Created < contexts[j].Created
+	})
+	report := statsReport{Contexts: len(contexts)}
+	now := time.Now()
+	roots := rootNames(ctxClient, contexts)
+	days := map[string]*statsDay{}
+	for d := startOfDay(cutoff); !d.After(now); d = d.AddDate(0, 0, 1) {
+		days[d.Format(dateFormat)] = &statsDay{Date: d.Format(dateFormat)}
+	}
+	byRoot := map[string]*statsContext{}
+	total := 0.0
+	var streak, longest *statsStreak
+	for _, c := range contexts {
+		created, completed, ok := contextSpan(c, now)
+		if !ok {
+			continue
+		}
+		spans := activeSpans(c.Notes, created, completed)
+		minutes := 0.0
+		for _, s := range spans {
+			minutes += s.End.Sub(s.Start).Minutes()
+		}
+		total += minutes
+		root := roots[c.ContextId]
+		if byRoot[root] == nil {
+			byRoot[root] = &statsContext{Name: root}
+		}
+		byRoot[root].Minutes += minutes
+		byRoot[root].Contexts++
+		if day := days[created.Format(dateFormat)]; day != nil && c.LastContext != "" {
+			day.Switches++
+			report.Switches++
+		}
+		// split the time over the days and hours it ran in, leaving out breaks
+		for _, s := range spans {
+			for t := s.Start; t.Before(s.End); {
+				next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+				if next.After(s.End) {
+					next = s.End
+				}
+				m := next.Sub(t).Minutes()
+				if day := days[t.Format(dateFormat)]; day != nil {
+					day.Minutes += m
+				}
+				report.Hours[t.Weekday()][t.Hour()] += m
+				t = next
+			}
+		}
+		// a streak keeps going through subcontexts of the same root as long
+		// as nothing else came in between
+		if streak != nil && streak.Name == root && created.Sub(parseLocal(streak.End)) <= 5*time.Minute {
+			streak.End = completed.Format(time.RFC3339)
+			streak.minutes += minutes
+		} else {
+			streak = &statsStreak{Name: root, Start: created.Format(time.RFC3339), End: completed.Format(time.RFC3339), minutes: minutes}
+		}
+		if longest == nil || streak.minutes > longest.minutes {
+			copied := *streak
+			longest = &copied
+		}
+	}
+	if longest != nil {
+		longest.Length = formatMinutes(longest.minutes)
+		report.LongestStreak = longest
+	}
+	report.Tracked = formatMinutes(total)
+	report.AverageLength = formatMinutes(total / float64(len(contexts)))
+	for _, day := range days {
+		day.Minutes = math.Round(day.Minutes)
+		day.Tracked = formatMinutes(day.Minutes)
+		report.Days = append(report.Days, *day)
+	}
+	sort.Slice(report.Days, func(i, j int) bool {
+		return report.Days[i].Date < report.Days[j].Date
+	})
+	for _, r := range byRoot {
+		r.Minutes = math.Round(r.Minutes)
+		r.Tracked = formatMinutes(r.Minutes)
+		report.TopContexts = append(report.TopContexts, *r)
+	}
+	sort.Slice(report.TopContexts, func(i, j int) bool {
+		return report.TopContexts[i].Minutes > report.TopContexts[j].Minutes
+	})
+	if len(report.TopContexts) > topCount {
+		report.TopContexts = report.TopContexts[:topCount]
+	}
+	for d := range report.Hours {
+		for h := range report.Hours[d] {
+			report.Hours[d][h] = math.Round(report.Hours[d][h])
+		}
+	}
+	return report
+}
+
+func contextSpan(c ctxclient.Context, now time.Time) (time.Time, time.Time, bool) {
+	created, err := time.Parse(ctxclient.SkDateFormat, c.Created)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	completed := now
+	if c.Completed != "" {
+		completed, err = time.Parse(ctxclient.SkDateFormat, c.Completed)
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	return created.Local(), completed.Local(), true
+}
+
+func parseLocal(t string) time.Time {
+	parsed, _ := time.Parse(time.RFC3339, t)
+	return parsed
+}
+
+func rootNames(ctxClient *ctxclient.ContextClient, contexts []ctxclient.Context) map[string]string {
+	roots := map[string]string{}
+	for id, lineage := range contextLineage(ctxClient, contexts) {
+		roots[id] = lineage[len(lineage)-1].Name
+	}
+	return roots
+}
+
+func renderStats(r statsReport) string {
+	output := fmt.Sprintf("last %s: %s tracked over %d contexts, %s on average, %d switches\n", r.Since, r.Tracked, r.Contexts, r.AverageLength, r.Switches)
+
+	output += "\ntracked per day\n"
+	maxDay, daily := 0.0, []float64{}
+	for _, d := range r.Days {
+		maxDay = math.Max(maxDay, d.Minutes)
+		daily = append(daily, d.Minutes)
+	}
+	output += "  " + sparkline(daily) + "\n"
+	for _, d := range r.Days {
+		date, _ := time.Parse(dateFormat, d.Date)
+		output += fmt.Sprintf("  %s %-20s %-7s %s\n", date.Format("Mon 01-02"), bar(d.Minutes, maxDay, 20), d.Tracked, plural(d.Switches, "switch", "switches"))
+	}
+
+	output += "\ntop contexts\n"
+	maxTop := 0.0
+	for _, c := range r.TopContexts {
+		maxTop = math.Max(maxTop, c.Minutes)
+	}
+	for _, c := range r.TopContexts {
+		output += fmt.Sprintf("  %-20s %-20s %s\n", truncate(c.Name, 20), bar(c.Minutes, maxTop, 20), c.Tracked)
+	}
+
+	if r.LongestStreak != nil {
+		s := r.LongestStreak
+		output += fmt.Sprintf("\nlongest streak: %s on '%s', %s to %s\n", s.Length, s.Name, parseLocal(s.Start).Format("Mon 01-02 15:04"), parseLocal(s.End).Format("15:04"))
+	}
+
+	output += "\nbusiest hours\n      0     6     12    18   23\n"
+	maxHour := 0.0
+	for _, day := range r.Hours {
+		for _, m := range day {
+			maxHour = math.Max(maxHour, m)
+		}
+	}
+	shades := []rune(" ░▒▓█")
+	// monday first
+	for i := 1; i <= 7; i++ {
+		d := time.Weekday(i % 7)
+		row := ""
+		for _, m := range r.Hours[d] {
+			shade := 0
+			if maxHour > 0 && m > 0 {
+				shade = 1 + int(m/maxHour*float64(len(shades)-2)+0.5)
+			}
+			row += string(shades[shade])
+		}
+		output += fmt.Sprintf("  %s %s\n", d.String()[:3], row)
+	}
+	return strings.TrimRight(output, "\n")
+}
+
+func sparkline(values []float64) string {
+	ticks := []rune("▁▂▃▄▅▆▇█")
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	line := ""
+	for _, v := range values {
+		if max == 0 || v == 0 {
+			line += " "
+			continue
+		}
+		line += string(ticks[int(v/max*float64(len(ticks)-1)+0.5)])
+	}
+	return line
+}
+
+func bar(value, max float64, width int) string {
+	if max <= 0 || value <= 0 {
+		return ""
+	}
+	eighths := int(value/max*float64(width*8) + 0.5)
+	partial := []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+	return strings.Repeat("█", eighths/8) + partial[eighths%8]
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, one)
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+func stringifyStats(r *statsReport) (string, error) {
+	rJson, err := jsonMarshalIndent(r, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return "", err
+	}
+	if EXPORT_TYPE == "yaml" {
+		return printYaml(rJson)
+	}
+	return string(rJson), nil
+}
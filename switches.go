@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/charlesrobsampson/ctxclient"
+)
+
+type switchPair struct {
+	Contexts [2]string `json:"contexts"`
+	Switches int       `json:"switches"`
+	// times we went back to where we were before the switch
+	Returns    int    `json:"returns"`
+	MedianBack string `json:"medianBack,omitempty"`
+	back       []float64
+}
+
+type switchDay struct {
+	Date     string  `json:"date"`
+	Switches int     `json:"switches"`
+	Contexts int     `json:"contexts"`
+	Tracked  string  `json:"tracked"`
+	PerHour  float64 `json:"perHour"`
+	// the average time between switches
+	Stretch string `json:"stretch"`
+}
+
+type switchReport struct {
+	Since      string       `json:"since"`
+	Switches   int          `json:"switches"`
+	Returns    int          `json:"returns"`
+	MedianBack string       `json:"medianBack,omitempty"`
+	Pairs      []switchPair `json:"pairs"`
+	Fragmented []switchDay  `json:"fragmented"`
+}
+
+func switchesCmd(ctxClient *ctxclient.ContextClient, args []string) string {
+	since, args := flagValue(args, "--since")
+	asJson, args := hasFlag(args, "--json")
+	top, _ := flagValue(args, "--top")
+	if since == "" {
+		since = "1w"
+	}
+	topCount := 5
+	if top != "" {
+		_, err := fmt.Sscan(top, &topCount)
+		if err != nil || topCount < 1 {
+			fmt.Printf("Error: invalid --top '%s'\n", top)
+			os.Exit(exitUsage)
+		}
+	}
+	start, unit, _, err := parseSince(since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	cs, err := ctxClient.ListContexts(ctxclient.QSParams{
+		"start": start,
+		"end":   "0",
+		"unit":  unit,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	report := contextSwitches(ctxClient, *cs, topCount)
+	report.Since = since
+	if report.Switches == 0 {
+		return fmt.Sprintf("no context switches in the last %s", since)
+	}
+	if asJson {
+		output, err := stringifySwitches(&report)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+		return output
+	}
+	return renderSwitches(report)
+}
+
+func contextSwitches(ctxClient *ctxclient.ContextClient, contexts []ctxclient.Context, topCount int) switchReport {
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].Created < contexts[j].Created
+	})
+	names := map[string]string{}
+	for _, c := range contexts {
+		names[c.ContextId] = c.Name
+	}
+	now := time.Now()
+	report := switchReport{}
+	pairs := map[[2]string]*switchPair{}
+	days := map[string]*switchDay{}
+	dayContexts := map[string]map[string]bool{}
+	dayMinutes := map[string]float64{}
+	allBack := []float64{}
+	for i, c := range contexts {
+		created, completed, ok := contextSpan(c, now)
+		if !ok {
+			continue
+		}
+		date := created.Format(dateFormat)
+		if days[date] == nil {
+			days[date] = &switchDay{Date: date}
+			dayContexts[date] = map[string]bool{}
+		}
+		dayContexts[date][c.Name] = true
+		dayMinutes[date] += completed.Sub(created).Minutes() - pausedMinutes(c.Notes, created, completed)
+		if c.LastContext == "" {
+			continue
+		}
+		from, ok := names[c.LastContext]
+		if !ok {
+			// switched from a context before the window
+			last, err := ctxClient.GetContext(c.LastContext)
+			if err == nil {
+				from = last.Name
+			}
+			names[c.LastContext] = from
+		}
+		if from == "" || from == c.Name {
+			continue
+		}
+		key := [2]string{from, c.Name}
+		if key[1] < key[0] {
+			key = [2]string{c.Name, from}
+		}
+		if pairs[key] == nil {
+			pairs[key] = &switchPair{Contexts: key}
+		}
+		pair := pairs[key]
+		pair.Switches++
+		report.Switches++
+		days[date].Switches++
+		// how long until we're back on the context we switched away from
+		for _, later := range contexts[i+1:] {
+			if later.Name != from {
+				continue
+			}
+			back, err := time.Parse(ctxclient.SkDateFormat, later.Created)
+			if err == nil {
+				minutes := back.Local().Sub(created).Minutes()
+				pair.back = append(pair.back, minutes)
+				allBack = append(allBack, minutes)
+				pair.Returns++
+				report.Returns++
+			}
+			break
+		}
+	}
+	if len(allBack) > 0 {
+		report.MedianBack = formatMinutes(median(allBack))
+	}
+	for _, pair := range pairs {
+		if len(pair.back) > 0 {
+			pair.MedianBack = formatMinutes(median(pair.back))
+		}
+		report.Pairs = append(report.Pairs, *pair)
+	}
+	sort.Slice(report.Pairs, func(i, j int) bool {
+		if report.Pairs[i].Switches != report.Pairs[j].Switches {
+			return report.Pairs[i].Switches > report.Pairs[j].Switches
+		}
+		return report.Pairs[i].Contexts[0] < report.Pairs[j].Contexts[0]
+	})
+	if len(report.Pairs) > topCount {
+		report.Pairs = report.Pairs[:topCount]
+	}
+	for date, day := range days {
+		if day.Switches == 0 {
+			continue
+		}
+		minutes := dayMinutes[date]
+		day.Contexts = len(dayContexts[date])
+		day.Tracked = formatMinutes(minutes)
+		day.Stretch = formatMinutes(minutes / float64(day.Switches+1))
+		if minutes > 0 {
+			day.PerHour = math.Round(float64(day.Switches)/(minutes/60)*10) / 10
+		}
+		report.Fragmented = append(report.Fragmented, *day)
+	}
+	// a day with a lot of switches in a little tracked time is the most cut up
+	sort.Slice(report.Fragmented, func(i, j int) bool {
+		if report.Fragmented[i].PerHour != report.Fragmented[j].PerHour {
+			return report.Fragmented[i].PerHour > report.Fragmented[j].PerHour
+		}
+		return report.Fragmented[i].Date > report.Fragmented[j].Date
+	})
+	if len(report.Fragmented) > topCount {
+		report.Fragmented = report.Fragmented[:topCount]
+	}
+	return report
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func renderSwitches(r switchReport) string {
+	output := fmt.Sprintf("last %s: %s, went back to the previous context %s", r.Since, plural(r.Switches, "switch", "switches"), plural(r.Returns, "time", "times"))
+	if r.MedianBack != "" {
+		output += fmt.Sprintf(" after %s (median)", r.MedianBack)
+	}
+	output += "\n\nbouncing between\n"
+	maxSwitches := 0.0
+	for _, p := range r.Pairs {
+		maxSwitches = math.Max(maxSwitches, float64(p.Switches))
+	}
+	for _, p := range r.Pairs {
+		back := ""
+		if p.MedianBack != "" {
+			back = fmt.Sprintf(", back after %s", p.MedianBack)
+		}
+		output += fmt.Sprintf("  %-20s <-> %-20s %-10s %s%s\n", truncate(p.Contexts[0], 20), truncate(p.Contexts[1], 20), bar(float64(p.Switches), maxSwitches, 10), plural(p.Switches, "switch", "switches"), back)
+	}
+	output += "\nmost fragmented days\n"
+	for _, d := range r.Fragmented {
+		date, _ := time.Parse(dateFormat, d.Date)
+		output += fmt.Sprintf("  %s %s over %s (%.1f an hour), %d contexts, %s between switches on average\n", date.Format("Mon 01-02"), plural(d.Switches, "switch", "switches"), d.Tracked, d.PerHour, d.Contexts, d.Stretch)
+	}
+	return output[:len(output)-1]
+}
+
+func stringifySwitches(r *switchReport) (string, error) {
+	rJson, err := jsonMarshalIndent(r, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return "", err
+	}
+	if EXPORT_TYPE == "yaml" {
+		return printYaml(rJson)
+	}
+	return string(rJson), nil
+}